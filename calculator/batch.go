@@ -0,0 +1,191 @@
+package calculator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"CalculatorWebService/calculator/storage"
+	"CalculatorWebService/internal/logger"
+	"CalculatorWebService/internal/negotiate"
+	"CalculatorWebService/internal/problem"
+	"CalculatorWebService/internal/requestid"
+)
+
+const (
+	defaultBatchWorkers  = 8
+	defaultBatchMaxItems = 1000
+)
+
+// BatchItem is a single operation of a batch. Operation is a registered operation name
+// (see GET /calculate/operations) or "expression", the other fields mirror the single-operation requests.
+type BatchItem struct {
+	Operation  string `json:"operation"`
+	Operand    Number `json:"operand"` // unary operations
+	Operand1   Number `json:"operand1"`
+	Operand2   Number `json:"operand2"`
+	Precision  *int   `json:"precision"`
+	Unit       string `json:"unit"`
+	Expression string `json:"expression"` // only for the "expression" operation
+}
+
+// BatchRequest accepts both {"items": [...]} and a bare array of items
+type BatchRequest struct {
+	Items []BatchItem `json:"items"`
+}
+
+func (r *BatchRequest) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return json.Unmarshal(trimmed, &r.Items)
+	}
+	type plain BatchRequest // no UnmarshalJSON, avoids the recursion
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// BatchItemResult holds either the response fields or the error of the item at Index.
+type BatchItemResult struct {
+	Index int   `json:"index"`
+	ID    int64 `json:"id,omitempty"` // ID of the stored calculation
+	*Response
+	Error    string `json:"error,omitempty"`
+	Code     string `json:"code,omitempty"`     // problem type code of the error, see GET /problems
+	Position *int   `json:"position,omitempty"` // expression errors only
+}
+
+type BatchResponse struct {
+	Results   []BatchItemResult `json:"results"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+}
+
+// Batch evaluates many operations in one request. Items are independent: a failing item is reported
+// in its result and doesn't affect the others. Successful items are stored with a single storage round-trip.
+func (h *Handler) Batch(c *gin.Context) {
+	start := time.Now()
+	var req BatchRequest
+	if err := negotiate.Bind(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if len(req.Items) == 0 {
+		problem.Respond(c, problemInvalidBatch, "batch must contain at least one item")
+		return
+	}
+	if len(req.Items) > h.BatchMaxItems {
+		problem.Respond(c, problemInvalidBatch, "batch must not contain more than "+strconv.Itoa(h.BatchMaxItems)+" items")
+		return
+	}
+
+	results := make([]BatchItemResult, len(req.Items))
+	durations := make([]time.Duration, len(req.Items))
+	h.evaluateBatch(c, req.Items, results, durations)
+
+	response := BatchResponse{Results: results}
+	records := make([]storage.Calculation, 0, len(results))
+	stored := make([]int, 0, len(results)) // result index of every record
+	for i, result := range results {
+		if result.Response == nil {
+			response.Failed++
+			continue
+		}
+		response.Succeeded++
+		records = append(records, result.Response.record(c.ClientIP(), c.Request.UserAgent(), requestid.Get(c), start, durations[i]))
+		stored = append(stored, i)
+	}
+
+	if len(records) > 0 {
+		saved, err := storage.StoreBatch(c.Request.Context(), h.Storage, records)
+		if err != nil {
+			logger.LogErrorContext(c.Request.Context(), "Failed to store batch", err, logrus.Fields{"items": len(records)})
+			problem.Respond(c, problemStorage, "failed to store calculations")
+			return
+		}
+		for i, record := range saved {
+			response.Results[stored[i]].ID = record.ID
+		}
+	}
+
+	negotiate.Render(c, http.StatusOK, response)
+}
+
+// evaluateBatch spreads the items over a bounded pool of workers, each result lands at the item's index
+func (h *Handler) evaluateBatch(c *gin.Context, items []BatchItem, results []BatchItemResult, durations []time.Duration) {
+	workers := h.BatchWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	ctx := c.Request.Context()
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				itemStart := time.Now()
+				results[i] = h.evaluateItem(ctx, i, items[i])
+				durations[i] = time.Since(itemStart)
+			}
+		}()
+	}
+
+	for i := range items {
+		if ctx.Err() != nil {
+			// the client is gone, don't bother evaluating the rest
+			results[i] = BatchItemResult{Index: i, Error: ctx.Err().Error()}
+			continue
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+func (h *Handler) evaluateItem(ctx context.Context, index int, item BatchItem) BatchItemResult {
+	result := BatchItemResult{Index: index}
+
+	response, err := evaluate(ctx, h, item.Operation, problem.InvalidRequest, func() (Response, error) {
+		if item.Operation == "expression" {
+			return evaluateExpression(item.Expression, nil)
+		}
+		return h.applyItem(item)
+	})
+	if err != nil {
+		p := problemFor(err, problem.InvalidRequest)
+		result.Error, result.Code, result.Position = p.Detail, p.Code, p.Position
+		return result
+	}
+	result.Response = &response
+	return result
+}
+
+func (h *Handler) applyItem(item BatchItem) (Response, error) {
+	if item.Operation == "" {
+		return Response{}, errors.New("operation is required")
+	}
+	op, ok := h.Operations.Get(item.Operation)
+	if !ok {
+		return Response{}, newDomainError(problemUnknownOperation, "unknown operation '"+item.Operation+"'")
+	}
+	digits, err := h.resolvePrecision(op, item.Precision)
+	if err != nil {
+		return Response{}, err
+	}
+	operands := []Number{item.Operand1, item.Operand2}
+	if op.Arity == 1 {
+		operands = []Number{item.Operand}
+	}
+	return op.apply(operands, item.Unit, digits)
+}
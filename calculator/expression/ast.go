@@ -0,0 +1,381 @@
+package expression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Node is an element of the parsed expression tree.
+// String returns the normalized form: single spaces around binary operators
+// and only the parentheses that are required by precedence.
+type Node interface {
+	String() string
+	Position() int
+}
+
+type Number struct {
+	Value float64
+	Pos   int
+}
+
+type Unary struct {
+	Operator string
+	Operand  Node
+	Pos      int
+}
+
+type Binary struct {
+	Operator string
+	Left     Node
+	Right    Node
+	Pos      int
+}
+
+type Variable struct {
+	Name string
+	Pos  int
+}
+
+// Assign is only valid as the root of the tree, it evaluates to the assigned value.
+// Storing the value is up to the caller, see Env.
+type Assign struct {
+	Name  string
+	Value Node
+	Pos   int
+}
+
+// Call invokes a user-defined function, see Functions
+type Call struct {
+	Name string
+	Args []Node
+	Pos  int
+}
+
+// Function is a user-defined function like "f(x, y) = x^2 + 3*y", see ParseFunction.
+// The body only sees the parameters, never the variables of the caller.
+type Function struct {
+	Name   string
+	Params []string
+	Body   Node
+}
+
+// Env resolves variables during evaluation
+type Env interface {
+	Lookup(name string) (float64, bool)
+}
+
+// Functions resolves user-defined functions during evaluation
+type Functions interface {
+	Function(name string) (*Function, bool)
+}
+
+// Default evaluation limits, they bound the work a single expression can cause through nested calls
+const (
+	DefaultMaxDepth = 32
+	DefaultMaxSteps = 100000
+)
+
+// Scope is everything an expression can refer to. Variables and Functions may be nil,
+// zero limits fall back to DefaultMaxDepth and DefaultMaxSteps.
+type Scope struct {
+	Variables Env
+	Functions Functions
+	MaxDepth  int // nesting of function calls
+	MaxSteps  int // evaluated nodes, function bodies included
+}
+
+// EvalError is a domain error (e.g. division by zero) raised while evaluating,
+// Position points at the operator that caused it. Code is stable, see the Code constants.
+type EvalError struct {
+	Position int
+	Code     string
+	Message  string
+}
+
+// Codes of evaluation errors, clients may branch on them
+const (
+	CodeDivisionByZero    = "division_by_zero"
+	CodeModuloByZero      = "modulo_by_zero"
+	CodeNotFinite         = "result_not_finite"
+	CodeUnknownVariable   = "unknown_variable"
+	CodeUnknownFunction   = "unknown_function"
+	CodeArgumentCount     = "argument_count"
+	CodeCallDepthExceeded = "call_depth_exceeded"
+	CodeStepLimitExceeded = "step_limit_exceeded"
+	CodeEvaluation        = "evaluation_error" // anything else
+)
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Position)
+}
+
+const (
+	precedenceAdditive = iota + 1
+	precedenceMultiplicative
+	precedenceUnary
+	precedencePower
+	precedenceAtom
+)
+
+func precedence(n Node) int {
+	switch node := n.(type) {
+	case *Binary:
+		return binaryPrecedence(node.Operator)
+	case *Unary:
+		return precedenceUnary
+	default:
+		return precedenceAtom
+	}
+}
+
+func binaryPrecedence(operator string) int {
+	switch operator {
+	case "+", "-":
+		return precedenceAdditive
+	case "*", "/", "%":
+		return precedenceMultiplicative
+	default:
+		return precedencePower
+	}
+}
+
+func (n *Number) Position() int   { return n.Pos }
+func (n *Unary) Position() int    { return n.Pos }
+func (n *Binary) Position() int   { return n.Pos }
+func (n *Variable) Position() int { return n.Pos }
+func (n *Assign) Position() int   { return n.Pos }
+func (n *Call) Position() int     { return n.Pos }
+
+func (n *Number) String() string {
+	return strconv.FormatFloat(n.Value, 'f', -1, 64)
+}
+
+func (n *Variable) String() string {
+	return n.Name
+}
+
+func (n *Assign) String() string {
+	return n.Name + " = " + n.Value.String()
+}
+
+func (n *Call) String() string {
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = arg.String()
+	}
+	return n.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (f *Function) String() string {
+	return f.Name + "(" + strings.Join(f.Params, ", ") + ") = " + f.Body.String()
+}
+
+// Calls returns the names of the functions called by the body, without duplicates
+func (f *Function) Calls() []string {
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	var walk func(Node)
+	walk = func(node Node) {
+		switch n := node.(type) {
+		case *Call:
+			if !seen[n.Name] {
+				seen[n.Name] = true
+				names = append(names, n.Name)
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *Unary:
+			walk(n.Operand)
+		case *Binary:
+			walk(n.Left)
+			walk(n.Right)
+		}
+	}
+	walk(f.Body)
+	return names
+}
+
+func (n *Unary) String() string {
+	operand := n.Operand.String()
+	// -(2 + 3) needs parentheses, -2^2 does not since power binds tighter
+	if precedence(n.Operand) < precedenceUnary {
+		operand = "(" + operand + ")"
+	}
+	return n.Operator + operand
+}
+
+func (n *Binary) String() string {
+	own := binaryPrecedence(n.Operator)
+	left, right := n.Left.String(), n.Right.String()
+
+	if n.Operator == "^" {
+		// right associative: the left side needs parentheses for anything but atoms,
+		// the right side is parsed as unary so only lower-precedence binaries need them
+		if precedence(n.Left) <= own {
+			left = "(" + left + ")"
+		}
+		if _, ok := n.Right.(*Binary); ok && precedence(n.Right) < own {
+			right = "(" + right + ")"
+		}
+	} else {
+		if precedence(n.Left) < own {
+			left = "(" + left + ")"
+		}
+		if precedence(n.Right) <= own {
+			right = "(" + right + ")"
+		}
+	}
+	return left + " " + n.Operator + " " + right
+}
+
+// Evaluate computes the value of a tree without variables.
+func Evaluate(n Node) (float64, error) {
+	return EvaluateIn(n, nil)
+}
+
+// EvaluateIn computes the value of the tree resolving variables from env, which may be nil.
+func EvaluateIn(n Node, env Env) (float64, error) {
+	return EvaluateScope(n, Scope{Variables: env})
+}
+
+// EvaluateScope computes the value of the tree with variables, functions and limits from scope.
+func EvaluateScope(n Node, scope Scope) (float64, error) {
+	if scope.MaxDepth <= 0 {
+		scope.MaxDepth = DefaultMaxDepth
+	}
+	if scope.MaxSteps <= 0 {
+		scope.MaxSteps = DefaultMaxSteps
+	}
+	e := &evaluator{scope: scope}
+	return e.eval(n, scope.Variables, 0)
+}
+
+type evaluator struct {
+	scope    Scope
+	steps    int
+	failedIn string // function whose body raised the error
+}
+
+func (e *evaluator) eval(n Node, env Env, depth int) (float64, error) {
+	if e.steps++; e.steps > e.scope.MaxSteps {
+		return 0, &EvalError{Position: n.Position(), Code: CodeStepLimitExceeded, Message: "evaluation exceeds " + strconv.Itoa(e.scope.MaxSteps) + " steps"}
+	}
+	switch node := n.(type) {
+	case *Number:
+		return node.Value, nil
+	case *Variable:
+		if env != nil {
+			if value, ok := env.Lookup(node.Name); ok {
+				return value, nil
+			}
+		}
+		return 0, &EvalError{Position: node.Pos, Code: CodeUnknownVariable, Message: "unknown variable '" + node.Name + "'"}
+	case *Assign:
+		return e.eval(node.Value, env, depth)
+	case *Unary:
+		value, err := e.eval(node.Operand, env, depth)
+		if err != nil {
+			return 0, err
+		}
+		if node.Operator == "-" {
+			return -value, nil
+		}
+		return value, nil
+	case *Binary:
+		left, err := e.eval(node.Left, env, depth)
+		if err != nil {
+			return 0, err
+		}
+		right, err := e.eval(node.Right, env, depth)
+		if err != nil {
+			return 0, err
+		}
+		return applyBinary(node, left, right)
+	case *Call:
+		return e.call(node, env, depth)
+	default:
+		return 0, &EvalError{Position: n.Position(), Code: CodeEvaluation, Message: "unsupported node"}
+	}
+}
+
+// call evaluates the arguments in the caller's env and the body with only the parameters bound
+func (e *evaluator) call(node *Call, env Env, depth int) (float64, error) {
+	var fn *Function
+	if e.scope.Functions != nil {
+		fn, _ = e.scope.Functions.Function(node.Name)
+	}
+	if fn == nil {
+		return 0, &EvalError{Position: node.Pos, Code: CodeUnknownFunction, Message: "unknown function '" + node.Name + "'"}
+	}
+	if len(node.Args) != len(fn.Params) {
+		return 0, &EvalError{Position: node.Pos, Code: CodeArgumentCount, Message: fmt.Sprintf("%s expects %d arguments, got %d", fn.Name, len(fn.Params), len(node.Args))}
+	}
+	if depth >= e.scope.MaxDepth {
+		return 0, &EvalError{Position: node.Pos, Code: CodeCallDepthExceeded, Message: "function calls nested deeper than " + strconv.Itoa(e.scope.MaxDepth)}
+	}
+
+	args := make(arguments, len(fn.Params))
+	for i, arg := range node.Args {
+		value, err := e.eval(arg, env, depth)
+		if err != nil {
+			return 0, err
+		}
+		args[fn.Params[i]] = value
+	}
+	value, err := e.eval(fn.Body, args, depth+1)
+	if err != nil {
+		if e.failedIn == "" {
+			e.failedIn = fn.Name // the innermost function is where it went wrong
+		}
+		var evalErr *EvalError
+		if depth == 0 && errors.As(err, &evalErr) {
+			// positions inside a body mean nothing to the caller, point at the call in the expression instead
+			return 0, &EvalError{Position: node.Pos, Code: evalErr.Code, Message: evalErr.Message + " in " + e.failedIn}
+		}
+		return 0, err
+	}
+	return value, nil
+}
+
+// arguments binds the parameters of a function while its body is evaluated
+type arguments map[string]float64
+
+func (a arguments) Lookup(name string) (float64, bool) {
+	value, ok := a[name]
+	return value, ok
+}
+
+func applyBinary(node *Binary, left, right float64) (float64, error) {
+	var result float64
+	switch node.Operator {
+	case "+":
+		result = left + right
+	case "-":
+		result = left - right
+	case "*":
+		result = left * right
+	case "/":
+		if right == 0 {
+			return 0, &EvalError{Position: node.Pos, Code: CodeDivisionByZero, Message: "division by zero"}
+		}
+		result = left / right
+	case "%":
+		if right == 0 {
+			return 0, &EvalError{Position: node.Pos, Code: CodeModuloByZero, Message: "modulo by zero"}
+		}
+		result = math.Mod(left, right)
+	case "^":
+		result = math.Pow(left, right)
+	default:
+		return 0, &EvalError{Position: node.Pos, Code: CodeEvaluation, Message: "unknown operator '" + node.Operator + "'"}
+	}
+
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, &EvalError{Position: node.Pos, Code: CodeNotFinite, Message: "result is not a finite number"}
+	}
+	return result, nil
+}
@@ -0,0 +1,262 @@
+package expression
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"16 / 4 / 2", 2},
+		{"7 % 3", 1},
+		{"2 ^ 3 ^ 2", 512}, // right associative
+		{"-2 ^ 2", -4},     // power binds tighter than unary minus
+		{"(-2) ^ 2", 4},
+		{"2 ^ -1", 0.5},
+		{"--3", 3},
+		{"+3", 3},
+		{"2 * -3", -6},
+		{"(3 + 4.5) * -2 / (1 - 0.25)", -20},
+		{"  1+2  ", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			tree, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got, err := Evaluate(tree)
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+// String is stored as the expression of the calculation, it must parse back to the same tree
+func TestString(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"1+2*3", "1 + 2 * 3"},
+		{"(1+2)*3", "(1 + 2) * 3"},
+		{"1-(2-3)", "1 - (2 - 3)"},
+		{"(1-2)-3", "1 - 2 - 3"},
+		{"-2^2", "-2 ^ 2"},
+		{"(-2)^2", "(-2) ^ 2"},
+		{"2^(3^2)", "2 ^ 3 ^ 2"},
+		{"(2^3)^2", "(2 ^ 3) ^ 2"},
+		{"-(2+3)", "-(2 + 3)"},
+		{"x = ans*2", "x = ans * 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			tree, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := tree.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			again, err := Parse(tree.String())
+			if err != nil {
+				t.Fatalf("String() doesn't parse back: %v", err)
+			}
+			if again.String() != tree.String() {
+				t.Errorf("round trip changed %q into %q", tree.String(), again.String())
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src      string
+		position int
+	}{
+		{"", 0},
+		{"   ", 3},
+		{"1 +", 3},
+		{"1 + * 2", 4},
+		{"1 2", 2},
+		{"(1 + 2", 0},
+		{"1 + 2)", 5},
+		{"2 $ 3", 2},
+		{"f(1,", 4},
+		{"1 = 2", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse(%q) error = %v, want a SyntaxError", tt.src, err)
+			}
+			if syntaxErr.Position != tt.position {
+				t.Errorf("Parse(%q) error at %d (%s), want %d", tt.src, syntaxErr.Position, syntaxErr.Message, tt.position)
+			}
+		})
+	}
+}
+
+// functionMap resolves the functions of a test from their definitions
+type functionMap map[string]*Function
+
+func (m functionMap) Function(name string) (*Function, bool) {
+	fn, ok := m[name]
+	return fn, ok
+}
+
+func mustFunctions(t *testing.T, definitions ...string) functionMap {
+	t.Helper()
+	functions := make(functionMap)
+	for _, definition := range definitions {
+		fn, err := ParseFunction(definition)
+		if err != nil {
+			t.Fatalf("ParseFunction(%q): %v", definition, err)
+		}
+		functions[fn.Name] = fn
+	}
+	return functions
+}
+
+type variables map[string]float64
+
+func (v variables) Lookup(name string) (float64, bool) {
+	value, ok := v[name]
+	return value, ok
+}
+
+func TestEvaluateScope(t *testing.T) {
+	functions := mustFunctions(t,
+		"sq(x) = x ^ 2",
+		"hyp(a, b) = (sq(a) + sq(b)) ^ 0.5",
+		"inv(x) = 1 / x",
+		"loop(x) = loop(x) + 1",
+	)
+	tests := []struct {
+		name     string
+		src      string
+		scope    Scope
+		want     float64
+		code     string // expected EvalError code, empty when the evaluation succeeds
+		position int
+	}{
+		{name: "variables", src: "x * 2 + y", scope: Scope{Variables: variables{"x": 3, "y": 1}}, want: 7},
+		{name: "nested calls", src: "hyp(3, 4)", want: 5},
+		{name: "parameters shadow variables", src: "sq(x)", scope: Scope{Variables: variables{"x": 3}}, want: 9},
+		{name: "division by zero", src: "1 / 0", code: CodeDivisionByZero, position: 2},
+		{name: "modulo by zero", src: "5 % 0", code: CodeModuloByZero, position: 2},
+		{name: "overflow", src: "10 ^ 400", code: CodeNotFinite, position: 3},
+		{name: "unknown variable", src: "1 + x", code: CodeUnknownVariable, position: 4},
+		{name: "unknown function", src: "nope(1)", code: CodeUnknownFunction, position: 0},
+		{name: "argument count", src: "hyp(1)", code: CodeArgumentCount, position: 0},
+		{name: "error in a body points at the call", src: "1 + inv(0)", code: CodeDivisionByZero, position: 4},
+		{name: "call depth", src: "loop(1)", scope: Scope{MaxDepth: 5}, code: CodeCallDepthExceeded, position: 0},
+		{name: "steps", src: "1 + 1 + 1 + 1 + 1 + 1", scope: Scope{MaxSteps: 5}, code: CodeStepLimitExceeded},
+		{name: "steps count function bodies", src: "hyp(3, 4)", scope: Scope{MaxSteps: 10}, code: CodeStepLimitExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			tt.scope.Functions = functions
+			got, err := EvaluateScope(tree, tt.scope)
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("%s: %v", tt.src, err)
+				}
+				if math.Abs(got-tt.want) > 1e-12 {
+					t.Errorf("%s = %v, want %v", tt.src, got, tt.want)
+				}
+				return
+			}
+			var evalErr *EvalError
+			if !errors.As(err, &evalErr) {
+				t.Fatalf("%s: error = %v, want an EvalError with code %s", tt.src, err, tt.code)
+			}
+			if evalErr.Code != tt.code {
+				t.Errorf("%s: code = %s (%s), want %s", tt.src, evalErr.Code, evalErr.Message, tt.code)
+			}
+			if tt.code != CodeStepLimitExceeded && evalErr.Position != tt.position {
+				t.Errorf("%s: position = %d, want %d", tt.src, evalErr.Position, tt.position)
+			}
+		})
+	}
+}
+
+func TestFunctionCalls(t *testing.T) {
+	fn, err := ParseFunction("f(x, y) = g(x) + h(y, g(1)) * x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(fn.Calls(), ","); got != "g,h" {
+		t.Errorf("Calls() = %s, want g,h", got)
+	}
+}
+
+// Regression: a body of a million parentheses overflowed the stack of the parser and killed the process.
+// Accepted expressions must print and evaluate within the same bounds.
+func TestParseLimits(t *testing.T) {
+	nested := func(open, close string, levels int) string {
+		return strings.Repeat(open, levels) + "1" + strings.Repeat(close, levels)
+	}
+	tests := []struct {
+		name     string
+		src      string
+		position int // of the SyntaxError, -1 when the expression parses
+	}{
+		{name: "parentheses within the limit", src: nested("(", ")", MaxNesting-1), position: -1},
+		{name: "parentheses beyond the limit", src: nested("(", ")", MaxNesting), position: MaxNesting},
+		{name: "deep parentheses", src: nested("(", ")", 2000), position: MaxNesting},
+		{name: "signs", src: nested("-", "", 3000), position: MaxNesting},
+		{name: "powers", src: strings.Repeat("2^", 2000) + "1", position: 2 * MaxNesting},
+		{name: "call arguments", src: nested("f(", ")", 1000), position: 2 * MaxNesting},
+		{name: "long chain", src: strings.Repeat("1+", MaxTokens/2-1) + "1", position: -1},
+		{name: "too long", src: strings.Repeat("1 + ", MaxTokens/2) + "1 + 1", position: 2 * MaxTokens},
+		{name: "large numbers print longer", src: strings.Repeat("1e300+", MaxTokens/2-1) + "1", position: -1},
+		{name: "million parentheses", src: nested("(", ")", 1000000), position: MaxTokens},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := Parse(tt.src)
+			if tt.position < 0 {
+				if err != nil {
+					t.Fatalf("Parse: %v", err)
+				}
+				if _, err := Parse(tree.String()); err != nil {
+					t.Errorf("String() doesn't parse back: %v", err)
+				}
+				if _, err := Evaluate(tree); err != nil {
+					t.Errorf("Evaluate: %v", err)
+				}
+				return
+			}
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("error = %v, want a SyntaxError", err)
+			}
+			if syntaxErr.Position != tt.position {
+				t.Errorf("error at %d (%s), want %d", syntaxErr.Position, syntaxErr.Message, tt.position)
+			}
+		})
+	}
+
+	if _, err := ParseFunction("f(x) = " + nested("(", ")", MaxNesting) + " + x"); err == nil {
+		t.Error("ParseFunction accepted a body nested beyond the limit")
+	}
+}
@@ -0,0 +1,313 @@
+package expression
+
+import "fmt"
+
+// SyntaxError points at the character where parsing failed.
+// Position is a zero-based rune offset into the original expression.
+type SyntaxError struct {
+	Position int
+	Message  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Position)
+}
+
+// Limits of the parser. Parsing, evaluating and printing recurse along the tree, so the nesting bounds
+// the recursion for parentheses, signs, powers and call arguments and the length bounds chains like 1+1+...+1.
+// Both are counted in tokens: the normalized form String returns never has more, so it always parses back.
+const (
+	// MaxTokens is the longest expression or function definition accepted
+	MaxTokens = 5000
+	// MaxNesting is how many levels of parentheses, signs, powers and calls may enclose a number
+	MaxNesting = 100
+)
+
+// Parse turns the source into an AST.
+// Grammar (lowest to highest precedence):
+//
+//	statement = [ identifier "=" ] expr   assignment only at the top level
+//	expr      = term { ("+" | "-") term }
+//	term      = unary { ("*" | "/" | "%") unary }
+//	unary     = ("-" | "+") unary | power
+//	power     = primary [ "^" unary ]     right associative
+//	primary   = number | identifier [ "(" [ expr { "," expr } ] ")" ] | "(" expr ")"
+func Parse(src string) (Node, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, &SyntaxError{Position: p.peek().pos, Message: "empty expression"}
+	}
+
+	node, err := p.parseStatement()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, &SyntaxError{Position: tok.pos, Message: "unexpected '" + tok.text + "'"}
+	}
+	return node, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int // nesting of the unary being parsed, see MaxNesting
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) isOperator(ops ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokenOperator {
+		return false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseStatement() (Node, error) {
+	if p.peek().kind == tokenIdent && p.tokens[p.pos+1].kind == tokenAssign {
+		name := p.next()
+		p.next() // "="
+		if p.peek().kind == tokenEOF {
+			return nil, &SyntaxError{Position: p.peek().pos, Message: "missing value of '" + name.text + "'"}
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &Assign{Name: name.text, Value: value, Pos: name.pos}, nil
+	}
+	return p.parseExpr()
+}
+
+func (p *parser) parseExpr() (Node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("+", "-") {
+		op := p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Operator: op.text, Left: left, Right: right, Pos: op.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("*", "/", "%") {
+		op := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Operator: op.text, Left: left, Right: right, Pos: op.pos}
+	}
+	return left, nil
+}
+
+// parseUnary is on every recursive path of the grammar, it enforces MaxNesting
+func (p *parser) parseUnary() (Node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > MaxNesting {
+		return nil, &SyntaxError{Position: p.peek().pos, Message: fmt.Sprintf("expression is nested deeper than %d levels", MaxNesting)}
+	}
+	if p.isOperator("-", "+") {
+		op := p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Operator: op.text, Operand: operand, Pos: op.pos}, nil
+	}
+	return p.parsePower()
+}
+
+func (p *parser) parsePower() (Node, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOperator("^") {
+		op := p.next()
+		// exponent goes through parseUnary so that 2^-1 works and 2^3^2 == 2^(3^2)
+		exponent, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Binary{Operator: op.text, Left: base, Right: exponent, Pos: op.pos}, nil
+	}
+	return base, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		return &Number{Value: tok.value, Pos: tok.pos}, nil
+	case tokenIdent:
+		if p.peek().kind == tokenLParen {
+			return p.parseCall(tok)
+		}
+		return &Variable{Name: tok.text, Pos: tok.pos}, nil
+	case tokenLParen:
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		closing := p.next()
+		if closing.kind != tokenRParen {
+			if closing.kind == tokenEOF {
+				return nil, &SyntaxError{Position: tok.pos, Message: "unclosed parenthesis"}
+			}
+			return nil, &SyntaxError{Position: closing.pos, Message: "expected ')' but found '" + closing.text + "'"}
+		}
+		return inner, nil
+	case tokenEOF:
+		return nil, &SyntaxError{Position: tok.pos, Message: "unexpected end of expression"}
+	default:
+		return nil, &SyntaxError{Position: tok.pos, Message: "unexpected '" + tok.text + "'"}
+	}
+}
+
+func (p *parser) parseCall(name token) (Node, error) {
+	open := p.next() // "("
+	call := &Call{Name: name.text, Args: make([]Node, 0), Pos: name.pos}
+	if p.peek().kind == tokenRParen {
+		p.next()
+		return call, nil
+	}
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+
+		tok := p.next()
+		switch tok.kind {
+		case tokenComma:
+			continue
+		case tokenRParen:
+			return call, nil
+		case tokenEOF:
+			return nil, &SyntaxError{Position: open.pos, Message: "unclosed parenthesis"}
+		default:
+			return nil, &SyntaxError{Position: tok.pos, Message: "expected ',' or ')' but found '" + tok.text + "'"}
+		}
+	}
+}
+
+// ParseFunction parses a function definition like "f(x, y) = x^2 + 3*y".
+// The body may only refer to the parameters, calls to other functions are resolved when evaluating.
+func ParseFunction(src string) (*Function, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+
+	name := p.next()
+	if name.kind != tokenIdent {
+		return nil, &SyntaxError{Position: name.pos, Message: "expected a function name"}
+	}
+	if tok := p.next(); tok.kind != tokenLParen {
+		return nil, &SyntaxError{Position: tok.pos, Message: "expected '(' after the function name"}
+	}
+
+	fn := &Function{Name: name.text, Params: make([]string, 0)}
+	seen := make(map[string]bool)
+	if p.peek().kind == tokenRParen {
+		p.next()
+	} else {
+		for {
+			param := p.next()
+			if param.kind != tokenIdent {
+				return nil, &SyntaxError{Position: param.pos, Message: "expected a parameter name"}
+			}
+			if seen[param.text] {
+				return nil, &SyntaxError{Position: param.pos, Message: "duplicate parameter '" + param.text + "'"}
+			}
+			seen[param.text] = true
+			fn.Params = append(fn.Params, param.text)
+
+			tok := p.next()
+			if tok.kind == tokenRParen {
+				break
+			}
+			if tok.kind != tokenComma {
+				return nil, &SyntaxError{Position: tok.pos, Message: "expected ',' or ')' but found '" + tok.text + "'"}
+			}
+		}
+	}
+
+	if tok := p.next(); tok.kind != tokenAssign {
+		return nil, &SyntaxError{Position: tok.pos, Message: "expected '=' after the parameters"}
+	}
+	if p.peek().kind == tokenEOF {
+		return nil, &SyntaxError{Position: p.peek().pos, Message: "missing function body"}
+	}
+	body, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, &SyntaxError{Position: tok.pos, Message: "unexpected '" + tok.text + "'"}
+	}
+	if unknown := freeVariable(body, seen); unknown != nil {
+		return nil, &SyntaxError{Position: unknown.Pos, Message: "unknown parameter '" + unknown.Name + "'"}
+	}
+	fn.Body = body
+	return fn, nil
+}
+
+// freeVariable returns the first variable of the tree that is not a parameter
+func freeVariable(node Node, params map[string]bool) *Variable {
+	switch n := node.(type) {
+	case *Variable:
+		if !params[n.Name] {
+			return n
+		}
+	case *Unary:
+		return freeVariable(n.Operand, params)
+	case *Binary:
+		if v := freeVariable(n.Left, params); v != nil {
+			return v
+		}
+		return freeVariable(n.Right, params)
+	case *Call:
+		for _, arg := range n.Args {
+			if v := freeVariable(arg, params); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Package feed broadcasts stored calculations to live subscribers (SSE, WebSocket, gRPC watch).
+package feed
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"CalculatorWebService/calculator/storage"
+)
+
+// replayPageSize is how many stored calculations are read at once while catching up
+const replayPageSize = 100
+
+// Hub fans calculations out to subscribers. Publishing never blocks: every subscriber has a bounded
+// buffer and a slow one loses its oldest events, which are counted in Subscription.Dropped.
+type Hub struct {
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+	mu          sync.RWMutex
+
+	// OnDrop is called for every event dropped because of a slow subscriber, e.g. to count it in metrics
+	OnDrop func()
+}
+
+func NewHub(bufferSize int) *Hub {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &Hub{
+		bufferSize:  bufferSize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives calculations of the given operations, or all of them when none are given.
+type Subscription struct {
+	hub        *Hub
+	operations map[string]bool
+	events     chan storage.Calculation
+	dropped    atomic.Uint64
+	sendMu     sync.Mutex // makes drop-oldest and the following send one step
+	closeOnce  sync.Once
+}
+
+// Subscribe registers a new subscriber, it must be released with Close.
+// On a closed hub the returned subscription is closed already.
+func (h *Hub) Subscribe(operations []string) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		events: make(chan storage.Calculation, h.bufferSize),
+	}
+	if len(operations) > 0 {
+		sub.operations = make(map[string]bool, len(operations))
+		for _, op := range operations {
+			sub.operations[op] = true
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		sub.closeOnce.Do(func() { close(sub.events) })
+		return sub
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers the calculation to every interested subscriber without waiting for any of them
+func (h *Hub) Publish(calc storage.Calculation) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subscribers {
+		if sub.wants(calc) {
+			sub.deliver(calc)
+		}
+	}
+}
+
+// Subscribers returns the number of active subscriptions
+func (h *Hub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers)
+}
+
+// Close ends every subscription, their Events channels get closed. Publish becomes a no-op.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		sub.closeOnce.Do(func() { close(sub.events) })
+		delete(h.subscribers, sub)
+	}
+}
+
+// Events is closed when the subscription or the whole hub is closed
+func (s *Subscription) Events() <-chan storage.Calculation {
+	return s.events
+}
+
+// Dropped is the total number of events this subscriber lost by not keeping up
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Replay sends the stored calculations after afterID, oldest first, and returns the ID of the last one
+// it has seen. Call it right after Subscribe: live events with IDs up to the returned one were already
+// replayed and should be skipped, anything stored meanwhile is waiting in Events.
+// afterID 0 means live events only, nothing is replayed.
+func (s *Subscription) Replay(ctx context.Context, store storage.Storage, afterID int64, send func(storage.Calculation) error) (int64, error) {
+	if afterID <= 0 {
+		return 0, nil
+	}
+	last := afterID
+	query := storage.Query{
+		Order:  storage.SortAscending,
+		Limit:  replayPageSize,
+		Cursor: storage.NewCursor(storage.SortAscending, afterID),
+	}
+	for op := range s.operations {
+		query.Operations = append(query.Operations, op)
+	}
+
+	for {
+		page, err := storage.QueryHistory(ctx, store, query)
+		if err != nil {
+			return last, err
+		}
+		for _, calc := range page.Calculations {
+			if err := send(calc); err != nil {
+				return last, err
+			}
+			last = calc.ID
+		}
+		if page.NextCursor == "" {
+			return last, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	delete(s.hub.subscribers, s)
+	s.hub.mu.Unlock()
+	s.closeOnce.Do(func() { close(s.events) })
+}
+
+func (s *Subscription) wants(calc storage.Calculation) bool {
+	return s.operations == nil || s.operations[calc.Operation]
+}
+
+// deliver must be called with the hub read lock held, so the channel can't be closed underneath
+func (s *Subscription) deliver(calc storage.Calculation) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	for {
+		select {
+		case s.events <- calc:
+			return
+		default:
+		}
+		// buffer is full: make room by dropping the oldest event
+		select {
+		case <-s.events:
+			s.dropped.Add(1)
+			if s.hub.OnDrop != nil {
+				s.hub.OnDrop()
+			}
+		default:
+			// the subscriber has just read one, retry the send
+		}
+	}
+}
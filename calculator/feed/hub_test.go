@@ -0,0 +1,165 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"CalculatorWebService/calculator/storage"
+)
+
+// received drains what is buffered for the subscription without waiting for more
+func received(sub *Subscription) []int64 {
+	var ids []int64
+	for {
+		select {
+		case calc, ok := <-sub.Events():
+			if !ok {
+				return ids
+			}
+			ids = append(ids, calc.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHubDropsTheOldestEvents(t *testing.T) {
+	hub := NewHub(3)
+	drops := 0
+	hub.OnDrop = func() { drops++ }
+	slow := hub.Subscribe(nil)
+	defer slow.Close()
+
+	for id := int64(1); id <= 5; id++ {
+		hub.Publish(storage.Calculation{ID: id, Operation: "addition"})
+	}
+	if got, want := received(slow), []int64{3, 4, 5}; !equalIDs(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+	if slow.Dropped() != 2 || drops != 2 {
+		t.Errorf("dropped %d, OnDrop called %d times, want 2 and 2", slow.Dropped(), drops)
+	}
+}
+
+func TestHubFiltersOperations(t *testing.T) {
+	hub := NewHub(10)
+	all := hub.Subscribe(nil)
+	defer all.Close()
+	powers := hub.Subscribe([]string{"power", "sqrt"})
+	defer powers.Close()
+
+	for i, op := range []string{"addition", "power", "sqrt", "division"} {
+		hub.Publish(storage.Calculation{ID: int64(i + 1), Operation: op})
+	}
+	if got, want := received(all), []int64{1, 2, 3, 4}; !equalIDs(got, want) {
+		t.Errorf("all operations: received %v, want %v", got, want)
+	}
+	if got, want := received(powers), []int64{2, 3}; !equalIDs(got, want) {
+		t.Errorf("power and sqrt: received %v, want %v", got, want)
+	}
+}
+
+func TestHubClose(t *testing.T) {
+	hub := NewHub(10)
+	sub := hub.Subscribe(nil)
+	closed := hub.Subscribe(nil)
+	closed.Close()
+	closed.Close() // twice is fine
+	if n := hub.Subscribers(); n != 1 {
+		t.Errorf("%d subscribers, want 1", n)
+	}
+
+	hub.Close()
+	hub.Publish(storage.Calculation{ID: 1})
+	if _, ok := <-sub.Events(); ok {
+		t.Error("event received after the hub is closed")
+	}
+	sub.Close()
+
+	late := hub.Subscribe(nil)
+	if _, ok := <-late.Events(); ok {
+		t.Error("subscription to a closed hub is open")
+	}
+	if n := hub.Subscribers(); n != 0 {
+		t.Errorf("%d subscribers, want 0", n)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage()
+	for i := 0; i < 2*replayPageSize+10; i++ {
+		op := "addition"
+		if i%2 == 1 {
+			op = "power"
+		}
+		if _, err := store.Store(ctx, storage.Calculation{Operation: op}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hub := NewHub(10)
+
+	tests := []struct {
+		name       string
+		operations []string
+		afterID    int64
+		first      int64 // ID of the first replayed calculation, 0 when nothing is
+		count      int
+		last       int64
+	}{
+		{"live only", nil, 0, 0, 0, 0},
+		{"several pages", nil, 5, 6, 2*replayPageSize + 5, 2*replayPageSize + 10},
+		{"filtered", []string{"power"}, 200, 202, 5, 2*replayPageSize + 10},
+		{"nothing newer", nil, 2*replayPageSize + 10, 0, 0, 2*replayPageSize + 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := hub.Subscribe(tt.operations)
+			defer sub.Close()
+			var ids []int64
+			last, err := sub.Replay(ctx, store, tt.afterID, func(calc storage.Calculation) error {
+				if len(ids) > 0 && calc.ID <= ids[len(ids)-1] {
+					t.Fatalf("replayed %d after %d", calc.ID, ids[len(ids)-1])
+				}
+				ids = append(ids, calc.ID)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ids) != tt.count || last != tt.last || (tt.count > 0 && ids[0] != tt.first) {
+				t.Errorf("replayed %d from %v up to %d, want %d from %d up to %d", len(ids), ids[:min(len(ids), 1)], last, tt.count, tt.first, tt.last)
+			}
+		})
+	}
+
+	t.Run("send fails", func(t *testing.T) {
+		sub := hub.Subscribe(nil)
+		defer sub.Close()
+		errGone := errors.New("client is gone")
+		sent := 0
+		last, err := sub.Replay(ctx, store, 1, func(calc storage.Calculation) error {
+			if sent == 3 {
+				return errGone
+			}
+			sent++
+			return nil
+		})
+		if !errors.Is(err, errGone) || last != 4 {
+			t.Errorf("got %d, %v, want 4, %v", last, err, errGone)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package feed
+
+import (
+	"context"
+	"sync"
+
+	"CalculatorWebService/calculator/storage"
+)
+
+// publishingStorage decorates a backend: every calculation it stores successfully is published to the hub.
+// Reads and Close go straight to the wrapped backend.
+type publishingStorage struct {
+	storage.Storage
+	hub *Hub
+	// mu spans storing and publishing, so subscribers get calculations in the order of their IDs.
+	// Backends assign IDs under a lock of their own, this adds only the non-blocking publish to it.
+	mu sync.Mutex
+}
+
+// Publishing wraps the backend so that every successful Store and StoreBatch reaches the hub,
+// no matter whether it came from the HTTP handlers, a batch or the gRPC API.
+func Publishing(backend storage.Storage, hub *Hub) storage.Storage {
+	return &publishingStorage{Storage: backend, hub: hub}
+}
+
+func (p *publishingStorage) Store(ctx context.Context, calc storage.Calculation) (storage.Calculation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	calc, err := p.Storage.Store(ctx, calc)
+	if err != nil {
+		return calc, err
+	}
+	p.hub.Publish(calc)
+	return calc, nil
+}
+
+func (p *publishingStorage) StoreBatch(ctx context.Context, calcs []storage.Calculation) ([]storage.Calculation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stored, err := storage.StoreBatch(ctx, p.Storage, calcs)
+	if err != nil {
+		return nil, err
+	}
+	for _, calc := range stored {
+		p.hub.Publish(calc)
+	}
+	return stored, nil
+}
+
+// Query is forwarded explicitly, the embedded interface doesn't carry the optional Querier
+func (p *publishingStorage) Query(ctx context.Context, query storage.Query) (storage.Page, error) {
+	return storage.QueryHistory(ctx, p.Storage, query)
+}
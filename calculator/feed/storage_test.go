@@ -0,0 +1,102 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"CalculatorWebService/calculator/storage"
+)
+
+// lateStorage returns from Store a moment after the ID is assigned, odd IDs later than even ones
+type lateStorage struct {
+	*storage.MemoryStorage
+}
+
+func (s lateStorage) Store(ctx context.Context, calc storage.Calculation) (storage.Calculation, error) {
+	calc, err := s.MemoryStorage.Store(ctx, calc)
+	if calc.ID%2 == 1 {
+		time.Sleep(time.Millisecond)
+	}
+	return calc, err
+}
+
+// Regression: a calculation could be published after one stored later, out of ID order
+func TestPublishingKeepsTheIDOrder(t *testing.T) {
+	const writers, perWriter = 8, 25
+	hub := NewHub(writers * perWriter)
+	sub := hub.Subscribe(nil)
+	defer sub.Close()
+	store := Publishing(lateStorage{storage.NewMemoryStorage()}, hub)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if _, err := store.Store(context.Background(), storage.Calculation{Operation: "addition"}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	ids := received(sub)
+	if len(ids) != writers*perWriter {
+		t.Fatalf("received %d calculations, want %d", len(ids), writers*perWriter)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("calculation %d published after %d", ids[i], ids[i-1])
+		}
+	}
+}
+
+// failingStorage refuses every write
+type failingStorage struct {
+	*storage.MemoryStorage
+}
+
+var errFull = errors.New("storage is full")
+
+func (s failingStorage) Store(context.Context, storage.Calculation) (storage.Calculation, error) {
+	return storage.Calculation{}, errFull
+}
+
+func (s failingStorage) StoreBatch(context.Context, []storage.Calculation) ([]storage.Calculation, error) {
+	return nil, errFull
+}
+
+func TestPublishingOnlyStoredCalculations(t *testing.T) {
+	ctx := context.Background()
+	hub := NewHub(10)
+	sub := hub.Subscribe(nil)
+	defer sub.Close()
+
+	store := Publishing(storage.NewMemoryStorage(), hub)
+	if _, err := store.Store(ctx, storage.Calculation{Operation: "addition"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.StoreBatch(ctx, store, make([]storage.Calculation, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := received(sub), []int64{1, 2, 3, 4}; !equalIDs(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+
+	failing := Publishing(failingStorage{storage.NewMemoryStorage()}, hub)
+	if _, err := failing.Store(ctx, storage.Calculation{}); !errors.Is(err, errFull) {
+		t.Errorf("Store: got %v, want %v", err, errFull)
+	}
+	if _, err := storage.StoreBatch(ctx, failing, make([]storage.Calculation, 3)); !errors.Is(err, errFull) {
+		t.Errorf("StoreBatch: got %v, want %v", err, errFull)
+	}
+	if got := received(sub); len(got) != 0 {
+		t.Errorf("failed writes published %v", got)
+	}
+}
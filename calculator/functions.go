@@ -0,0 +1,199 @@
+package calculator
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"CalculatorWebService/calculator/expression"
+	"CalculatorWebService/calculator/functions"
+	"CalculatorWebService/internal/logger"
+	"CalculatorWebService/internal/negotiate"
+	"CalculatorWebService/internal/problem"
+)
+
+const (
+	tenantHeader  = "X-Tenant-ID"
+	defaultTenant = "default"
+)
+
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type DefineFunctionRequest struct {
+	Definition string `form:"definition" json:"definition" binding:"required"` // e.g. "f(x, y) = x^2 + 3*y"
+}
+
+type FunctionsResponse struct {
+	Functions []functions.Definition `json:"functions"`
+}
+
+// DefineFunction adds a function to the tenant of the request, it can be called right away from /functions/evaluate
+func (h *Handler) DefineFunction(c *gin.Context) {
+	tenant, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	fn, ok := bindFunction(c)
+	if !ok {
+		return
+	}
+
+	def, err := h.Functions.Define(c.Request.Context(), tenant, fn)
+	if err != nil {
+		respondFunctionError(c, err)
+		return
+	}
+	negotiate.Render(c, http.StatusCreated, def)
+}
+
+func (h *Handler) ListFunctions(c *gin.Context) {
+	set, ok := h.tenantFunctions(c)
+	if !ok {
+		return
+	}
+	negotiate.Render(c, http.StatusOK, FunctionsResponse{Functions: set.List()})
+}
+
+func (h *Handler) DeleteFunction(c *gin.Context) {
+	tenant, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	if err := h.Functions.Delete(c.Request.Context(), tenant, c.Param("name")); err != nil {
+		respondFunctionError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// EvaluateFunctions evaluates an expression calling the tenant's functions, e.g. "f(2, 3) + 1",
+// the result is stored like any other expression.
+func (h *Handler) EvaluateFunctions(c *gin.Context) {
+	start := time.Now()
+	set, ok := h.tenantFunctions(c)
+	if !ok {
+		return
+	}
+	var req ExpressionRequest
+	if err := negotiate.Bind(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	response, err := evaluate(c.Request.Context(), h, "expression", problemEvaluation, func() (Response, error) {
+		return evaluateExpression(req.Expression, set)
+	})
+	if err != nil {
+		respondError(c, err, problemEvaluation)
+		return
+	}
+
+	if err := h.store(c, response, start); err != nil {
+		problem.Respond(c, problemStorage, "failed to store calculation")
+		return
+	}
+
+	negotiate.Render(c, http.StatusOK, response)
+}
+
+// DefineSessionFunction adds a function to the session, it's gone when the session expires
+func (h *Handler) DefineSessionFunction(c *gin.Context) {
+	s, ok := h.session(c)
+	if !ok {
+		return
+	}
+	fn, ok := bindFunction(c)
+	if !ok {
+		return
+	}
+
+	def, err := s.Functions().Define(fn)
+	if err != nil {
+		respondFunctionError(c, err)
+		return
+	}
+	negotiate.Render(c, http.StatusCreated, def)
+}
+
+func (h *Handler) ListSessionFunctions(c *gin.Context) {
+	s, ok := h.session(c)
+	if !ok {
+		return
+	}
+	negotiate.Render(c, http.StatusOK, FunctionsResponse{Functions: s.Functions().List()})
+}
+
+func (h *Handler) DeleteSessionFunction(c *gin.Context) {
+	s, ok := h.session(c)
+	if !ok {
+		return
+	}
+	if err := s.Functions().Delete(c.Param("name")); err != nil {
+		respondFunctionError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// requestTenant reads the tenant from the X-Tenant-ID header, requests without one share the default tenant
+func requestTenant(c *gin.Context) (string, bool) {
+	tenant := c.GetHeader(tenantHeader)
+	if tenant == "" {
+		return defaultTenant, true
+	}
+	if !tenantPattern.MatchString(tenant) {
+		problem.Respond(c, problemInvalidTenant, tenantHeader+" must be 1-64 letters, digits, '.', '_' or '-'")
+		return "", false
+	}
+	return tenant, true
+}
+
+func (h *Handler) tenantFunctions(c *gin.Context) (*functions.Set, bool) {
+	tenant, ok := requestTenant(c)
+	if !ok {
+		return nil, false
+	}
+	set, err := h.Functions.Tenant(c.Request.Context(), tenant)
+	if err != nil {
+		logger.LogErrorContext(c.Request.Context(), "Failed to load functions", err)
+		problem.Respond(c, problemStorage, "failed to load functions")
+		return nil, false
+	}
+	return set, true
+}
+
+func bindFunction(c *gin.Context) (*expression.Function, bool) {
+	var req DefineFunctionRequest
+	if err := negotiate.Bind(c, &req); err != nil {
+		respondBindError(c, err)
+		return nil, false
+	}
+	fn, err := expression.ParseFunction(req.Definition)
+	if err != nil {
+		respondError(c, err, problemInvalidExpression)
+		return nil, false
+	}
+	return fn, true
+}
+
+func respondFunctionError(c *gin.Context, err error) {
+	var cycleErr *functions.CycleError
+	switch {
+	case errors.As(err, &cycleErr):
+		p := problemFunctionCycle.New(err.Error())
+		p.Cycle = cycleErr.Path
+		problem.Write(c, p)
+	case errors.Is(err, functions.ErrExists):
+		problem.Respond(c, problemFunctionExists, err.Error())
+	case errors.Is(err, functions.ErrNotFound):
+		problem.Respond(c, problemFunctionNotFound, err.Error())
+	case errors.Is(err, functions.ErrTooMany):
+		problem.Respond(c, problemTooManyFunctions, err.Error())
+	default:
+		logger.LogErrorContext(c.Request.Context(), "Failed to update functions", err)
+		problem.Respond(c, problemStorage, "failed to update functions")
+	}
+}
@@ -0,0 +1,150 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+
+	"CalculatorWebService/calculator/expression"
+	"CalculatorWebService/calculator/storage"
+	"CalculatorWebService/internal/logger"
+)
+
+// Library holds the function sets of all tenants. A tenant's set is loaded from the store on first use
+// and every change is written through, so definitions survive restarts.
+// Only tenants with functions are kept in memory: requests naming ever new tenants cost a store read each,
+// not memory.
+type Library struct {
+	store   storage.FunctionStore // nil keeps functions in memory only
+	limits  Limits
+	tenants map[string]*Set
+	mu      sync.Mutex // guards tenants, never held during store calls
+
+	// loads makes concurrent requests of a tenant that isn't in memory share one store read
+	loads singleflight.Group
+
+	// writes serializes Define and Delete, so the sets and the store change in the same order and
+	// a failed write can be undone without another change slipping in between
+	writes sync.Mutex
+}
+
+func NewLibrary(store storage.FunctionStore, limits Limits) *Library {
+	return &Library{
+		store:   store,
+		limits:  limits,
+		tenants: make(map[string]*Set),
+	}
+}
+
+// Tenant returns the set of the tenant, loading it from the store when it isn't in memory.
+// The set of a tenant without functions is not kept, Define keeps it once it has one.
+func (l *Library) Tenant(ctx context.Context, tenant string) (*Set, error) {
+	l.mu.Lock()
+	set, ok := l.tenants[tenant]
+	l.mu.Unlock()
+	if ok {
+		return set, nil
+	}
+
+	// a canceled request must not fail the others waiting for the same load
+	loaded, err, _ := l.loads.Do(tenant, func() (interface{}, error) {
+		return l.load(context.WithoutCancel(ctx), tenant)
+	})
+	if err != nil {
+		return nil, err
+	}
+	set = loaded.(*Set)
+	if set.empty() {
+		return set, nil
+	}
+	return l.keep(tenant, set), nil
+}
+
+// keep puts the set of the tenant in memory and returns the one in memory, which may have been
+// loaded by a concurrent request in the meantime. Both hold the same functions: a set only differs
+// from the store during a write, and writes work on the kept set once the tenant has functions.
+func (l *Library) keep(tenant string, set *Set) *Set {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if kept, ok := l.tenants[tenant]; ok {
+		return kept
+	}
+	l.tenants[tenant] = set
+	return set
+}
+
+func (l *Library) load(ctx context.Context, tenant string) (*Set, error) {
+	set := NewSet(l.limits)
+	if l.store == nil {
+		return set, nil
+	}
+	defs, err := l.store.ListFunctions(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	for _, def := range defs {
+		fn, err := expression.ParseFunction(def.Definition)
+		if err != nil {
+			// one broken record must not take the whole tenant down
+			logger.LogErrorContext(ctx, "Skipping stored function", err, logrus.Fields{"tenant": tenant, "function": def.Name})
+			continue
+		}
+		set.restore(fn, def.CreatedAt)
+	}
+	return set, nil
+}
+
+// Define persists the function and then adds it to the tenant, it can't be called before it is stored
+func (l *Library) Define(ctx context.Context, tenant string, fn *expression.Function) (Definition, error) {
+	set, err := l.Tenant(ctx, tenant)
+	if err != nil {
+		return Definition{}, err
+	}
+	l.writes.Lock()
+	defer l.writes.Unlock()
+
+	e, err := set.check(fn)
+	if err != nil {
+		return Definition{}, err
+	}
+	def := e.definition()
+	if l.store != nil {
+		err := l.store.SaveFunction(ctx, storage.FunctionDefinition{
+			Scope:      tenant,
+			Name:       def.Name,
+			Definition: def.Definition,
+			CreatedAt:  def.CreatedAt,
+		})
+		if err != nil {
+			return Definition{}, err
+		}
+	}
+	set.restore(e.fn, e.createdAt)
+	l.keep(tenant, set)
+	return def, nil
+}
+
+// Delete removes the function from the tenant and the store, it is put back when the store fails
+func (l *Library) Delete(ctx context.Context, tenant, name string) error {
+	set, err := l.Tenant(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	l.writes.Lock()
+	defer l.writes.Unlock()
+
+	removed, err := set.remove(name)
+	if err != nil {
+		return err
+	}
+	if l.store != nil {
+		if err := l.store.DeleteFunction(ctx, tenant, name); err != nil && !errors.Is(err, storage.ErrFunctionNotFound) {
+			set.restore(removed.fn, removed.createdAt)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"CalculatorWebService/calculator/storage"
+)
+
+var errStoreDown = errors.New("store is down")
+
+// failingStore is a memory store whose writes fail while down is set
+type failingStore struct {
+	*storage.MemoryStorage
+	down bool
+}
+
+func (s *failingStore) SaveFunction(ctx context.Context, def storage.FunctionDefinition) error {
+	if s.down {
+		return errStoreDown
+	}
+	return s.MemoryStorage.SaveFunction(ctx, def)
+}
+
+func (s *failingStore) DeleteFunction(ctx context.Context, scope, name string) error {
+	if s.down {
+		return errStoreDown
+	}
+	return s.MemoryStorage.DeleteFunction(ctx, scope, name)
+}
+
+func TestLibraryRollsBackFailedWrites(t *testing.T) {
+	ctx := context.Background()
+	store := &failingStore{MemoryStorage: storage.NewMemoryStorage()}
+	library := NewLibrary(store, Limits{})
+
+	if _, err := library.Define(ctx, "alice", mustFunction(t, "f(x) = x * 2")); err != nil {
+		t.Fatal(err)
+	}
+
+	store.down = true
+	if _, err := library.Define(ctx, "alice", mustFunction(t, "g(x) = x + 1")); !errors.Is(err, errStoreDown) {
+		t.Fatalf("Define with the store down: error = %v, want %v", err, errStoreDown)
+	}
+	if err := library.Delete(ctx, "alice", "f"); !errors.Is(err, errStoreDown) {
+		t.Fatalf("Delete with the store down: error = %v, want %v", err, errStoreDown)
+	}
+
+	set, err := library.Tenant(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := set.Function("g"); ok {
+		t.Error("g is callable although it was never stored")
+	}
+	if _, ok := set.Function("f"); !ok {
+		t.Error("f is gone although deleting it from the store failed")
+	}
+
+	// the sets match the store, so a fresh library loads the same functions
+	store.down = false
+	reloaded, err := NewLibrary(store, Limits{}).Tenant(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(reloaded.List()), len(set.List()); got != want {
+		t.Errorf("reloaded %d functions, the library has %d", got, want)
+	}
+	if _, err := library.Define(ctx, "alice", mustFunction(t, "g(x) = x + 1")); err != nil {
+		t.Errorf("Define once the store is back: %v", err)
+	}
+}
+
+// Regression: every new X-Tenant-ID used to stay in memory for good, even on reads
+func TestLibraryKeepsOnlyTenantsWithFunctions(t *testing.T) {
+	ctx := context.Background()
+	library := NewLibrary(storage.NewMemoryStorage(), Limits{})
+	kept := func() int {
+		library.mu.Lock()
+		defer library.mu.Unlock()
+		return len(library.tenants)
+	}
+
+	for i := 0; i < 100; i++ {
+		tenant := fmt.Sprintf("reader-%d", i)
+		if _, err := library.Tenant(ctx, tenant); err != nil {
+			t.Fatal(err)
+		}
+		if err := library.Delete(ctx, tenant, "f"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Delete on an empty tenant: error = %v, want ErrNotFound", err)
+		}
+		var cycleErr *CycleError
+		if _, err := library.Define(ctx, tenant, mustFunction(t, "f(x) = f(x)")); !errors.As(err, &cycleErr) {
+			t.Fatalf("Define of a recursive function: error = %v, want a CycleError", err)
+		}
+	}
+	if n := kept(); n != 0 {
+		t.Fatalf("%d tenants kept after reads and failed writes, want 0", n)
+	}
+
+	if _, err := library.Define(ctx, "alice", mustFunction(t, "f(x) = x")); err != nil {
+		t.Fatal(err)
+	}
+	set, err := library.Tenant(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := set.Function("f"); !ok || kept() != 1 {
+		t.Errorf("defined function found: %v, tenants kept: %d, want true, 1", ok, kept())
+	}
+
+	// a tenant with stored functions is kept once read, e.g. after a restart
+	reloaded := NewLibrary(library.store, Limits{})
+	first, err := reloaded.Tenant(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := reloaded.Tenant(ctx, "alice"); second != first {
+		t.Error("a tenant with functions was loaded again instead of kept")
+	}
+}
+
+// slowStore blocks ListFunctions of the slow tenant until release is closed and counts the calls
+type slowStore struct {
+	*storage.MemoryStorage
+	release chan struct{}
+	lists   atomic.Int32
+}
+
+func (s *slowStore) ListFunctions(ctx context.Context, scope string) ([]storage.FunctionDefinition, error) {
+	s.lists.Add(1)
+	if scope == "slow" {
+		<-s.release
+	}
+	return s.MemoryStorage.ListFunctions(ctx, scope)
+}
+
+// A slow store read must only hold up the requests of its tenant, and they share the read
+func TestLibraryLoadsTenantsConcurrently(t *testing.T) {
+	ctx := context.Background()
+	store := &slowStore{MemoryStorage: storage.NewMemoryStorage(), release: make(chan struct{})}
+	if err := store.MemoryStorage.SaveFunction(ctx, storage.FunctionDefinition{Scope: "slow", Name: "f", Definition: "f(x) = x"}); err != nil {
+		t.Fatal(err)
+	}
+	library := NewLibrary(store, Limits{})
+
+	const readers = 10
+	sets := make([]*Set, readers)
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			set, err := library.Tenant(ctx, "slow")
+			if err != nil {
+				t.Error(err)
+			}
+			sets[i] = set
+		}(i)
+	}
+	for store.lists.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond) // the other readers join the pending read
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := library.Tenant(ctx, "fast")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("loading a tenant waited for the store read of another one")
+	}
+
+	close(store.release)
+	wg.Wait()
+	if n := store.lists.Load(); n != 2 {
+		t.Errorf("store read %d times, want once per tenant", n)
+	}
+	for i, set := range sets {
+		if set != sets[0] {
+			t.Fatalf("reader %d got another set than reader 0", i)
+		}
+	}
+	if _, ok := sets[0].Function("f"); !ok {
+		t.Error("stored function was not loaded")
+	}
+}
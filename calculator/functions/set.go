@@ -0,0 +1,198 @@
+// Package functions manages user-defined functions of the expression language: a Set per session
+// or tenant, recursion checks when a function is defined and the limits applied when one is called.
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"CalculatorWebService/calculator/expression"
+)
+
+var (
+	ErrExists   = errors.New("function already exists, delete it first to redefine it")
+	ErrNotFound = errors.New("function not found")
+	ErrTooMany  = errors.New("too many functions")
+)
+
+// Limits bound what a Set can hold and what a single evaluation can cost
+type Limits struct {
+	MaxFunctions int // per set
+	MaxDepth     int // nesting of function calls
+	MaxSteps     int // evaluated nodes per expression
+}
+
+// CycleError is returned when a definition would make a function call itself, directly or through others
+type CycleError struct {
+	Path []string // e.g. f, g, f
+}
+
+func (e *CycleError) Error() string {
+	return "recursive definition: " + strings.Join(e.Path, " -> ")
+}
+
+// Definition describes a function for clients
+type Definition struct {
+	Name       string    `json:"name"`
+	Params     []string  `json:"params"`
+	Definition string    `json:"definition"` // normalized, e.g. "f(x, y) = x ^ 2 + 3 * y"
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type entry struct {
+	fn        *expression.Function
+	createdAt time.Time
+}
+
+// Set is a group of functions that can call each other. Calls to functions that are not defined yet
+// are allowed in a definition and only fail when evaluated, so cycles are checked on every definition.
+type Set struct {
+	limits    Limits
+	functions map[string]entry
+	mu        sync.RWMutex
+}
+
+func NewSet(limits Limits) *Set {
+	return &Set{
+		limits:    limits,
+		functions: make(map[string]entry),
+	}
+}
+
+// Function resolves functions for expression.EvaluateScope
+func (s *Set) Function(name string) (*expression.Function, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.functions[name]
+	return e.fn, ok
+}
+
+// Evaluate computes the tree with the functions of the set and its limits, variables come from env which may be nil
+func (s *Set) Evaluate(tree expression.Node, env expression.Env) (float64, error) {
+	return expression.EvaluateScope(tree, expression.Scope{
+		Variables: env,
+		Functions: s,
+		MaxDepth:  s.limits.MaxDepth,
+		MaxSteps:  s.limits.MaxSteps,
+	})
+}
+
+// Define adds the function, it fails with ErrExists, a CycleError or when the set is full
+func (s *Set) Define(fn *expression.Function) (Definition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.prepare(fn)
+	if err != nil {
+		return Definition{}, err
+	}
+	s.functions[fn.Name] = e
+	return e.definition(), nil
+}
+
+// check tells whether Define would accept the function, without adding it
+func (s *Set) check(fn *expression.Function) (entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.prepare(fn)
+}
+
+// prepare returns the entry of a function that can be added, it must be called with the lock held
+func (s *Set) prepare(fn *expression.Function) (entry, error) {
+	if _, exists := s.functions[fn.Name]; exists {
+		return entry{}, ErrExists
+	}
+	if s.limits.MaxFunctions > 0 && len(s.functions) >= s.limits.MaxFunctions {
+		return entry{}, fmt.Errorf("%w, the limit is %d", ErrTooMany, s.limits.MaxFunctions)
+	}
+	if path := s.cycle(fn); path != nil {
+		return entry{}, &CycleError{Path: path}
+	}
+	return entry{fn: fn, createdAt: time.Now().UTC()}, nil
+}
+
+// restore adds a function that was checked when it was first defined, e.g. loaded from storage
+func (s *Set) restore(fn *expression.Function, createdAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.functions[fn.Name] = entry{fn: fn, createdAt: createdAt}
+}
+
+func (s *Set) Delete(name string) error {
+	_, err := s.remove(name)
+	return err
+}
+
+// remove deletes the function and returns what was removed, lookup and deletion under one lock
+func (s *Set) remove(name string) (entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.functions[name]
+	if !ok {
+		return entry{}, ErrNotFound
+	}
+	delete(s.functions, name)
+	return e, nil
+}
+
+func (s *Set) empty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.functions) == 0
+}
+
+// List returns the definitions sorted by name
+func (s *Set) List() []Definition {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	defs := make([]Definition, 0, len(s.functions))
+	for _, e := range s.functions {
+		defs = append(defs, e.definition())
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// cycle walks the call graph from the new function and returns the path back to it, nil when there is none.
+// Every function of the set was acyclic when it was added, so only paths through fn need checking.
+// It must be called with the lock held.
+func (s *Set) cycle(fn *expression.Function) []string {
+	visited := make(map[string]bool)
+	var walk func(name string, path []string) []string
+	walk = func(name string, path []string) []string {
+		callee := fn
+		if name != fn.Name {
+			e, ok := s.functions[name]
+			if !ok {
+				return nil // not defined yet, checked when it is
+			}
+			callee = e.fn
+		}
+		for _, next := range callee.Calls() {
+			if next == fn.Name {
+				return append(path, next)
+			}
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			if found := walk(next, append(path, next)); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return walk(fn.Name, []string{fn.Name})
+}
+
+func (e entry) definition() Definition {
+	return Definition{
+		Name:       e.fn.Name,
+		Params:     e.fn.Params,
+		Definition: e.fn.String(),
+		CreatedAt:  e.createdAt,
+	}
+}
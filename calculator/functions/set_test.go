@@ -0,0 +1,97 @@
+package functions
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"CalculatorWebService/calculator/expression"
+)
+
+func mustFunction(t *testing.T, definition string) *expression.Function {
+	t.Helper()
+	fn, err := expression.ParseFunction(definition)
+	if err != nil {
+		t.Fatalf("ParseFunction(%q): %v", definition, err)
+	}
+	return fn
+}
+
+// The definitions are added in order, all but the last must be accepted.
+// The last one is rejected with the cycle path when wantCycle is set.
+func TestSetCycle(t *testing.T) {
+	tests := []struct {
+		name        string
+		definitions []string
+		wantCycle   []string
+	}{
+		{name: "direct recursion", definitions: []string{"f(x) = f(x - 1)"}, wantCycle: []string{"f", "f"}},
+		{name: "mutual recursion", definitions: []string{"g(x) = f(x)", "f(x) = g(x) + 1"}, wantCycle: []string{"f", "g", "f"}},
+		{name: "long chain", definitions: []string{"a(x) = b(x)", "b(x) = c(x)", "c(x) = a(x)"}, wantCycle: []string{"c", "a", "b", "c"}},
+		{name: "cycle in an argument", definitions: []string{"a(x) = b(1, x)", "b(x, y) = x + sq(a(y))"}, wantCycle: []string{"b", "a", "b"}},
+		{name: "diamond", definitions: []string{"d(x) = x", "b(x) = d(x)", "c(x) = d(x) * 2", "a(x) = b(x) + c(x)"}},
+		{name: "undefined callee", definitions: []string{"h(x) = k(x)"}},
+		{name: "callee defined later", definitions: []string{"h(x) = k(x)", "k(x) = x ^ 2"}},
+		{name: "same name as a parameter", definitions: []string{"x(x) = x * 2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := NewSet(Limits{})
+			last := len(tt.definitions) - 1
+			for _, definition := range tt.definitions[:last] {
+				if _, err := set.Define(mustFunction(t, definition)); err != nil {
+					t.Fatalf("Define(%q): %v", definition, err)
+				}
+			}
+
+			_, err := set.Define(mustFunction(t, tt.definitions[last]))
+			var cycleErr *CycleError
+			if tt.wantCycle == nil {
+				if err != nil {
+					t.Fatalf("Define(%q): %v", tt.definitions[last], err)
+				}
+				return
+			}
+			if !errors.As(err, &cycleErr) {
+				t.Fatalf("Define(%q) error = %v, want a CycleError", tt.definitions[last], err)
+			}
+			if !reflect.DeepEqual(cycleErr.Path, tt.wantCycle) {
+				t.Errorf("cycle = %v, want %v", cycleErr.Path, tt.wantCycle)
+			}
+			if _, ok := set.Function(mustFunction(t, tt.definitions[last]).Name); ok {
+				t.Error("the rejected function was added to the set")
+			}
+		})
+	}
+}
+
+func TestSetLimits(t *testing.T) {
+	set := NewSet(Limits{MaxFunctions: 2})
+	for _, definition := range []string{"f(x) = x", "g(x) = f(x) * 2"} {
+		if _, err := set.Define(mustFunction(t, definition)); err != nil {
+			t.Fatalf("Define(%q): %v", definition, err)
+		}
+	}
+	if _, err := set.Define(mustFunction(t, "f(x) = x + 1")); !errors.Is(err, ErrExists) {
+		t.Errorf("redefining f: error = %v, want ErrExists", err)
+	}
+	if _, err := set.Define(mustFunction(t, "h(x) = x")); !errors.Is(err, ErrTooMany) {
+		t.Errorf("third function: error = %v, want ErrTooMany", err)
+	}
+	if err := set.Delete("h"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting a missing function: error = %v, want ErrNotFound", err)
+	}
+	if err := set.Delete("f"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Define(mustFunction(t, "h(x) = x")); err != nil {
+		t.Errorf("Define after Delete: %v", err)
+	}
+	names := make([]string, 0)
+	for _, def := range set.List() {
+		names = append(names, def.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"g", "h"}) {
+		t.Errorf("List() = %v, want [g h]", names)
+	}
+}
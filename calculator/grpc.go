@@ -0,0 +1,317 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	calculatorv1 "CalculatorWebService/api/calculator/v1"
+	"CalculatorWebService/calculator/storage"
+	"CalculatorWebService/internal/logger"
+	"CalculatorWebService/internal/problem"
+	"CalculatorWebService/internal/ratelimit"
+	"CalculatorWebService/internal/requestid"
+)
+
+// grpcServer exposes the same operations as the HTTP handlers, it reuses the Handler
+// so both APIs share the registry, the precision default and the storage.
+type grpcServer struct {
+	calculatorv1.UnimplementedCalculatorServer
+	handler *Handler
+}
+
+func (g *grpcServer) Calculate(ctx context.Context, req *calculatorv1.CalculateRequest) (*calculatorv1.CalculateResponse, error) {
+	start := time.Now()
+	op, ok := g.handler.Operations.Get(req.GetOperation())
+	if !ok {
+		return nil, grpcError(ctx, problemUnknownOperation.New(fmt.Sprintf("unknown operation '%s'", req.GetOperation())))
+	}
+
+	var requested *int
+	if req.Precision != nil {
+		digits := int(req.GetPrecision())
+		requested = &digits
+	}
+	digits, err := g.handler.resolvePrecision(op, requested)
+	if err != nil {
+		g.handler.observe(op.Name, err, problem.InvalidRequest, 0)
+		return nil, grpcError(ctx, problemFor(err, problem.InvalidRequest))
+	}
+
+	operands := make([]Number, len(req.GetOperands()))
+	for i, operand := range req.GetOperands() {
+		operands[i] = Number(operand)
+	}
+	response, err := evaluate(ctx, g.handler, op.Name, problem.InvalidRequest, func() (Response, error) {
+		return op.apply(operands, req.GetUnit(), digits)
+	})
+	if err != nil {
+		return nil, grpcError(ctx, problemFor(err, problem.InvalidRequest))
+	}
+
+	return g.store(ctx, response, start)
+}
+
+func (g *grpcServer) Evaluate(ctx context.Context, req *calculatorv1.EvaluateRequest) (*calculatorv1.CalculateResponse, error) {
+	start := time.Now()
+	response, err := evaluate(ctx, g.handler, "expression", problemEvaluation, func() (Response, error) {
+		return evaluateExpression(req.GetExpression(), nil)
+	})
+	if err != nil {
+		return nil, grpcError(ctx, problemFor(err, problemEvaluation))
+	}
+	return g.store(ctx, response, start)
+}
+
+func (g *grpcServer) ListOperations(context.Context, *calculatorv1.ListOperationsRequest) (*calculatorv1.ListOperationsResponse, error) {
+	operations := g.handler.Operations.List()
+	response := &calculatorv1.ListOperationsResponse{
+		Operations: make([]*calculatorv1.Operation, 0, len(operations)),
+	}
+	for _, op := range operations {
+		response.Operations = append(response.Operations, &calculatorv1.Operation{
+			Name:        op.Name,
+			Path:        "/calculate/" + op.Path,
+			Symbol:      op.Symbol,
+			Notation:    string(op.Notation),
+			Arity:       int32(op.Arity),
+			Precise:     op.Precise != nil,
+			Angular:     op.Angular,
+			Constraints: op.Constraints,
+		})
+	}
+	return response, nil
+}
+
+func (g *grpcServer) ListHistory(ctx context.Context, req *calculatorv1.ListHistoryRequest) (*calculatorv1.ListHistoryResponse, error) {
+	query := storage.Query{
+		Operations: req.GetOperations(),
+		MinResult:  req.MinResult,
+		MaxResult:  req.MaxResult,
+		Search:     req.GetSearch(),
+		RequestID:  req.GetRequestId(),
+		Limit:      int(req.GetLimit()),
+		Cursor:     req.GetCursor(),
+	}
+	if req.From != nil {
+		query.From = req.GetFrom().AsTime()
+	}
+	if req.To != nil {
+		query.To = req.GetTo().AsTime()
+	}
+	switch req.GetOrder() {
+	case calculatorv1.SortOrder_SORT_ORDER_ASC:
+		query.Order = storage.SortAscending
+	case calculatorv1.SortOrder_SORT_ORDER_DESC:
+		query.Order = storage.SortDescending
+	}
+	query, err := checkHistoryQuery(query)
+	if err != nil {
+		return nil, grpcError(ctx, problemInvalidQuery.New(err.Error()))
+	}
+
+	page, err := storage.QueryHistory(ctx, g.handler.Storage, query)
+	if errors.Is(err, storage.ErrQueryUnsupported) {
+		return nil, grpcError(ctx, problemQueryUnsupported.New(err.Error()))
+	}
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		return nil, grpcError(ctx, problemInvalidCursor.New(err.Error()))
+	}
+	if errors.Is(err, storage.ErrInvalidQuery) {
+		return nil, grpcError(ctx, problemInvalidQuery.New(err.Error()))
+	}
+	if err != nil {
+		logger.LogErrorContext(ctx, "Failed to query history", err)
+		return nil, grpcError(ctx, problemStorage.New("failed to read calculations"))
+	}
+
+	response := &calculatorv1.ListHistoryResponse{
+		Calculations: make([]*calculatorv1.Calculation, len(page.Calculations)),
+		NextCursor:   page.NextCursor,
+	}
+	for i, calc := range page.Calculations {
+		response.Calculations[i] = toProtoCalculation(calc)
+	}
+	return response, nil
+}
+
+// WatchHistory streams calculations from the live feed. With after_id the history stored after it
+// is replayed first, so a reconnecting client doesn't miss anything.
+func (g *grpcServer) WatchHistory(req *calculatorv1.WatchHistoryRequest, stream grpc.ServerStreamingServer[calculatorv1.Calculation]) error {
+	ctx := stream.Context()
+	sub := g.handler.Feed.Subscribe(req.GetOperations())
+	defer sub.Close()
+
+	send := func(calc storage.Calculation) error {
+		return stream.Send(toProtoCalculation(calc))
+	}
+	replayed, err := sub.Replay(ctx, g.handler.Storage, req.GetAfterId(), send)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil // the client went away
+		}
+		logger.LogErrorContext(ctx, "Failed to replay calculations", err)
+		return grpcError(ctx, problemStorage.New("failed to read calculations"))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case calc, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			if calc.ID <= replayed {
+				continue
+			}
+			if err := send(calc); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (g *grpcServer) store(ctx context.Context, response Response, start time.Time) (*calculatorv1.CalculateResponse, error) {
+	clientIP, userAgent := peerInfo(ctx)
+	calc, err := g.handler.Storage.Store(ctx, response.record(clientIP, userAgent, requestid.FromContext(ctx), start, time.Since(start)))
+	if err != nil {
+		logger.LogErrorContext(ctx, "Failed to store calculation", err)
+		return nil, grpcError(ctx, problemStorage.New("failed to store calculation"))
+	}
+	return &calculatorv1.CalculateResponse{
+		Result:      response.Result,
+		ExactResult: response.ExactResult,
+		Precision:   int32(response.Precision),
+		Unit:        response.Unit,
+		Operation:   response.Operation,
+		Expression:  response.Expression,
+		Id:          calc.ID,
+	}, nil
+}
+
+// grpcError is the gRPC counterpart of problem.Write: the code follows the HTTP status of the problem and
+// the problem travels as an ErrorInfo detail, so gRPC clients branch on the same codes as HTTP clients.
+// The reason is the problem code, the metadata carry its type URI and the position of expression errors.
+func grpcError(ctx context.Context, p *problem.Problem) error {
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	info := &errdetails.ErrorInfo{
+		Reason:   p.Code,
+		Domain:   grpcErrorDomain,
+		Metadata: map[string]string{"type": p.Type},
+	}
+	if p.Position != nil {
+		info.Metadata["position"] = strconv.Itoa(*p.Position)
+	}
+	st, err := status.New(grpcCode(p.Status), p.Detail).WithDetails(info)
+	if err != nil {
+		return status.Error(grpcCode(p.Status), p.Detail)
+	}
+	return st.Err()
+}
+
+// grpcErrorDomain names the service in ErrorInfo details
+const grpcErrorDomain = "calculator"
+
+// grpcCode maps the HTTP status of a problem to the closest gRPC code
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if httpStatus >= 500 {
+		return codes.Internal
+	}
+	return codes.InvalidArgument
+}
+
+// rateLimitUnaryInterceptor refuses the calls of a client over the limit, the gRPC counterpart of Limiter.Middleware.
+// Clients are told apart by the peer address.
+func rateLimitUnaryInterceptor(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		clientIP, _ := peerInfo(ctx)
+		if ok, retryAfter := limiter.Allow(clientIP); !ok {
+			return nil, grpcError(ctx, problem.RateLimited.New(fmt.Sprintf("too many calls, retry in %s", retryAfter.Round(time.Millisecond))))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// recoveryUnaryInterceptor turns a panic into codes.Internal, the gRPC counterpart of gin.Recovery
+func recoveryUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.LogErrorContext(ctx, "Panic in gRPC handler", fmt.Errorf("%v", r), logrus.Fields{"method": info.FullMethod, "stack": string(debug.Stack())})
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func recoveryStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.LogErrorContext(ss.Context(), "Panic in gRPC handler", fmt.Errorf("%v", r), logrus.Fields{"method": info.FullMethod, "stack": string(debug.Stack())})
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// peerInfo is the gRPC counterpart of gin's ClientIP and UserAgent
+func peerInfo(ctx context.Context) (clientIP, userAgent string) {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		clientIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(clientIP); err == nil {
+			clientIP = host
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("user-agent"); len(values) > 0 {
+			userAgent = values[0]
+		}
+	}
+	return clientIP, userAgent
+}
+
+func toProtoCalculation(calc storage.Calculation) *calculatorv1.Calculation {
+	return &calculatorv1.Calculation{
+		Id:         calc.ID,
+		Timestamp:  timestamppb.New(calc.Timestamp),
+		Operation:  calc.Operation,
+		Operands:   calc.Operands,
+		Result:     calc.Result,
+		Expression: calc.Expression,
+		ClientIp:   calc.ClientIP,
+		UserAgent:  calc.UserAgent,
+		Duration:   durationpb.New(calc.Duration),
+		RequestId:  calc.RequestID,
+	}
+}
@@ -0,0 +1,120 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	calculatorv1 "CalculatorWebService/api/calculator/v1"
+	"CalculatorWebService/calculator/storage"
+	"CalculatorWebService/internal/ratelimit"
+)
+
+// brokenStorage fails every write, like a full disk
+type brokenStorage struct {
+	storage.Storage
+}
+
+func (brokenStorage) Store(context.Context, storage.Calculation) (storage.Calculation, error) {
+	return storage.Calculation{}, errors.New("disk is full")
+}
+
+// errorInfo returns the code of the status and its ErrorInfo detail
+func errorInfo(t *testing.T, err error) (codes.Code, *errdetails.ErrorInfo) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("%v is not a gRPC status", err)
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return st.Code(), info
+		}
+	}
+	return st.Code(), nil
+}
+
+// Regression: every error was INVALID_ARGUMENT with nothing but a message
+func TestGRPCErrors(t *testing.T) {
+	s := newTestService(t)
+	defer s.Shutdown(context.Background())
+	g := &grpcServer{handler: s.handler}
+
+	tests := []struct {
+		name     string
+		call     func(ctx context.Context) error
+		code     codes.Code
+		reason   string
+		position string
+	}{
+		{"unknown operation", func(ctx context.Context) error {
+			_, err := g.Calculate(ctx, &calculatorv1.CalculateRequest{Operation: "modulus", Operands: []string{"1", "2"}})
+			return err
+		}, codes.InvalidArgument, "unknown_operation", ""},
+		{"division by zero", func(ctx context.Context) error {
+			_, err := g.Calculate(ctx, &calculatorv1.CalculateRequest{Operation: "division", Operands: []string{"1", "0"}})
+			return err
+		}, codes.InvalidArgument, "division_by_zero", ""},
+		{"syntax error", func(ctx context.Context) error {
+			_, err := g.Evaluate(ctx, &calculatorv1.EvaluateRequest{Expression: "2 * (3 +"})
+			return err
+		}, codes.InvalidArgument, "invalid_expression", "8"},
+		{"evaluation error", func(ctx context.Context) error {
+			_, err := g.Evaluate(ctx, &calculatorv1.EvaluateRequest{Expression: "1 + 4 / (2 - 2)"})
+			return err
+		}, codes.InvalidArgument, "division_by_zero", "6"},
+		{"invalid query", func(ctx context.Context) error {
+			_, err := g.ListHistory(ctx, &calculatorv1.ListHistoryRequest{Cursor: "not a cursor"})
+			return err
+		}, codes.InvalidArgument, "invalid_cursor", ""},
+		{"storage failure", func(ctx context.Context) error {
+			h := *s.handler
+			h.Storage = brokenStorage{h.Storage}
+			_, err := (&grpcServer{handler: &h}).Evaluate(ctx, &calculatorv1.EvaluateRequest{Expression: "1 + 1"})
+			return err
+		}, codes.Internal, "storage_error", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, info := errorInfo(t, tt.call(context.Background()))
+			if code != tt.code {
+				t.Errorf("code = %s, want %s", code, tt.code)
+			}
+			if info == nil {
+				t.Fatal("no ErrorInfo detail")
+			}
+			if info.Reason != tt.reason || info.Metadata["type"] != "/problems/"+tt.reason || info.Metadata["position"] != tt.position {
+				t.Errorf("detail = %s %v, want %s at %q", info.Reason, info.Metadata, tt.reason, tt.position)
+			}
+		})
+	}
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := g.Calculate(ctx, &calculatorv1.CalculateRequest{Operation: "addition", Operands: []string{"1", "2"}})
+		if code := status.Code(err); code != codes.Canceled {
+			t.Errorf("code = %s, want %s", code, codes.Canceled)
+		}
+	})
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	interceptor := rateLimitUnaryInterceptor(ratelimit.New(0.001, 1))
+	handler := func(context.Context, interface{}) (interface{}, error) { return "ok", nil }
+	info := &grpc.UnaryServerInfo{FullMethod: "/calculator.v1.Calculator/Calculate"}
+
+	if _, err := interceptor(context.Background(), nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	_, err := interceptor(context.Background(), nil, info, handler)
+	code, detail := errorInfo(t, err)
+	if code != codes.ResourceExhausted || detail == nil || detail.Reason != "rate_limited" {
+		t.Errorf("over the limit: %s %v, want %s rate_limited", code, detail, codes.ResourceExhausted)
+	}
+}
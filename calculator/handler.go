@@ -1,31 +1,119 @@
 package calculator
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"CalculatorWebService/calculator/expression"
+	"CalculatorWebService/calculator/feed"
+	"CalculatorWebService/calculator/functions"
+	"CalculatorWebService/calculator/precise"
+	"CalculatorWebService/calculator/session"
 	"CalculatorWebService/calculator/storage"
+	"CalculatorWebService/internal/logger"
+	"CalculatorWebService/internal/metrics"
+	"CalculatorWebService/internal/negotiate"
+	"CalculatorWebService/internal/problem"
+	"CalculatorWebService/internal/requestid"
+	"CalculatorWebService/internal/tracing"
 )
 
+// Request operands accept JSON numbers as well as decimal strings ("0.1"),
+// the latter avoid float loss before the precise mode gets to see them.
 type Request struct {
-	Operand1 float64 `form:"operand1" json:"operand1"`
-	Operand2 float64 `form:"operand2" json:"operand2"`
+	Operand1  Number `form:"operand1" json:"operand1"`
+	Operand2  Number `form:"operand2" json:"operand2"`
+	Precision *int   `form:"precision" json:"precision"` // significant digits, 0 forces float mode, nil uses the configured default
+	Unit      string `form:"unit" json:"unit"`           // only used by angular operations
+}
+
+// UnaryRequest is used by single-operand operations, so clients don't have to send a meaningless operand2
+type UnaryRequest struct {
+	Operand Number `form:"operand" json:"operand"`
+	Unit    string `form:"unit" json:"unit"` // radians (default) or degrees, only used by trigonometric operations
+}
+
+type ExpressionRequest struct {
+	Expression string `form:"expression" json:"expression" binding:"required"`
 }
 
 type Response struct {
-	Result     float64 `json:"result"`
-	Operation  string  `json:"operation"`
-	Expression string  `json:"expression"`
+	Result      float64 `json:"result"`
+	ExactResult string  `json:"exact_result,omitempty"` // only in precise mode
+	Precision   int     `json:"precision,omitempty"`
+	Unit        string  `json:"unit,omitempty"` // only for trigonometric operations
+	Operation   string  `json:"operation"`
+	Expression  string  `json:"expression"`
+
+	operands []string // formatted operands for the stored record
+}
+
+type OperationInfo struct {
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	Symbol      string   `json:"symbol"`
+	Notation    Notation `json:"notation"`
+	Arity       int      `json:"arity"`
+	Precise     bool     `json:"precise"` // supports the precision field
+	Angular     bool     `json:"angular"` // supports the unit field
+	Constraints []string `json:"constraints"`
+}
+
+type OperationsResponse struct {
+	Operations []OperationInfo `json:"operations"`
 }
 
+// RecentResponse keeps the legacy "5 + 3 = 8" strings in Calculations for older clients,
+// Records carries the same calculations as structured records
 type RecentResponse struct {
-	Calculations []string `json:"calculations"`
+	Calculations []string              `json:"calculations"`
+	Records      []storage.Calculation `json:"records"`
+}
+
+// HistoryRequest is bound from the query string of GET /calculate/history
+type HistoryRequest struct {
+	Operation []string  `form:"operation"`
+	From      time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"` // RFC 3339, inclusive
+	To        time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`   // RFC 3339, exclusive
+	MinResult *float64  `form:"min_result"`
+	MaxResult *float64  `form:"max_result"`
+	Search    string    `form:"search"`     // case-insensitive substring of the expression
+	RequestID string    `form:"request_id"` // calculations made by a single request, see X-Request-ID
+	Order     string    `form:"order"`      // desc (default) or asc
+	Limit     int       `form:"limit"`
+	Cursor    string    `form:"cursor"` // next_cursor of the previous page
+}
+
+type HistoryResponse struct {
+	Calculations []storage.Calculation `json:"calculations"`
+	NextCursor   string                `json:"next_cursor,omitempty"`
 }
 
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
 type Handler struct {
-	Storage storage.Storage
+	Storage    storage.Storage
+	Operations *Registry
+	Precision  int       // default significant digits for the precise mode, 0 means float64 arithmetic
+	Feed       *feed.Hub // live feed of stored calculations, Storage publishes to it
+	Sessions   *session.Manager
+	Functions  *functions.Library // user-defined functions of every tenant
+	Metrics    *metrics.Metrics   // business metrics, nil disables them
+
+	BatchWorkers  int // size of the worker pool evaluating a single batch
+	BatchMaxItems int
 }
 
 // Considering the scope of the service it's okay to use a single instance of Handler and perform the logic inside methods.
@@ -34,124 +122,342 @@ type Handler struct {
 // For example, we could have a CalculatorService struct that would handle the operations and storage interactions.
 // Handlers would then call methods on that service.
 
-func NewCalculationHandler(storage storage.Storage) *Handler {
+func NewCalculationHandler(storage storage.Storage, operations *Registry, precision int, hub *feed.Hub, sessions *session.Manager, library *functions.Library) *Handler {
 	return &Handler{
-		Storage: storage,
+		Storage:    storage,
+		Feed:       hub,
+		Sessions:   sessions,
+		Functions:  library,
+		Operations: operations,
+		Precision:  precision,
+
+		BatchWorkers:  defaultBatchWorkers,
+		BatchMaxItems: defaultBatchMaxItems,
 	}
 }
 
-// Addition handler
-func (h *Handler) Addition(c *gin.Context) {
-	var req Request
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+// Calculate returns the handler for a registered operation. All operations share it,
+// the operation only defines how operands are validated, evaluated and rendered.
+func (h *Handler) Calculate(op Operation) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		var operands []Number
+		var unit string
+		var precision *int
+		if op.Arity == 1 {
+			var req UnaryRequest
+			if err := negotiate.Bind(c, &req); err != nil {
+				respondBindError(c, err)
+				return
+			}
+			operands, unit = []Number{req.Operand}, req.Unit
+		} else {
+			var req Request
+			if err := negotiate.Bind(c, &req); err != nil {
+				respondBindError(c, err)
+				return
+			}
+			operands, unit, precision = []Number{req.Operand1, req.Operand2}, req.Unit, req.Precision
+		}
 
-	result := req.Operand1 + req.Operand2
-	expression := formatExpression(req.Operand1, req.Operand2, "+", result)
+		digits, err := h.resolvePrecision(op, precision)
+		if err != nil {
+			h.observe(op.Name, err, problem.InvalidRequest, 0)
+			respondError(c, err, problem.InvalidRequest)
+			return
+		}
 
-	h.Storage.Store(expression)
+		response, err := evaluate(c.Request.Context(), h, op.Name, problem.InvalidRequest, func() (Response, error) {
+			return op.apply(operands, unit, digits)
+		})
+		if err != nil {
+			respondError(c, err, problem.InvalidRequest)
+			return
+		}
 
-	response := Response{
-		Result:     result,
-		Operation:  "addition",
-		Expression: expression,
+		if err := h.store(c, response, start); err != nil {
+			problem.Respond(c, problemStorage, "failed to store calculation")
+			return
+		}
+
+		negotiate.Render(c, http.StatusOK, response)
 	}
+}
 
-	c.JSON(http.StatusOK, response)
+// resolvePrecision picks the request precision over the configured default.
+// Operations without a precise implementation always use float mode,
+// an explicit precision in the request is rejected for them instead of being silently ignored.
+func (h *Handler) resolvePrecision(op Operation, requested *int) (int, error) {
+	digits := h.Precision
+	if requested != nil {
+		digits = *requested
+	}
+	if digits < 0 || digits > precise.MaxDigits {
+		return 0, newDomainError(problemInvalidPrecision, "precision must be between 0 and "+strconv.Itoa(precise.MaxDigits))
+	}
+	if op.Precise == nil {
+		if digits > 0 && requested != nil {
+			return 0, newDomainError(problemPreciseNotAllowed, "precise mode is not supported for "+op.Name)
+		}
+		return 0, nil
+	}
+	return digits, nil
 }
 
-// Subtraction handler
-func (h *Handler) Subtraction(c *gin.Context) {
-	var req Request
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// ListOperations is the discovery endpoint, clients can build their UI from it
+func (h *Handler) ListOperations(c *gin.Context) {
+	operations := h.Operations.List()
+	response := OperationsResponse{
+		Operations: make([]OperationInfo, 0, len(operations)),
+	}
+	for _, op := range operations {
+		info := OperationInfo{
+			Name:        op.Name,
+			Path:        "/calculate/" + op.Path,
+			Symbol:      op.Symbol,
+			Notation:    op.Notation,
+			Arity:       op.Arity,
+			Precise:     op.Precise != nil,
+			Angular:     op.Angular,
+			Constraints: op.Constraints,
+		}
+		if info.Constraints == nil {
+			info.Constraints = []string{}
+		}
+		response.Operations = append(response.Operations, info)
 	}
 
-	result := req.Operand1 - req.Operand2
-	expression := formatExpression(req.Operand1, req.Operand2, "-", result)
+	negotiate.Render(c, http.StatusOK, response)
+}
 
-	h.Storage.Store(expression)
+// Expression handler parses and evaluates a free-form expression like "(3 + 4.5) * -2 / (1 - 0.25)"
+func (h *Handler) Expression(c *gin.Context) {
+	start := time.Now()
+	var req ExpressionRequest
+	if err := negotiate.Bind(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
 
-	response := Response{
-		Result:     result,
-		Operation:  "subtraction",
-		Expression: expression,
+	response, err := evaluate(c.Request.Context(), h, "expression", problemEvaluation, func() (Response, error) {
+		return evaluateExpression(req.Expression, nil)
+	})
+	if err != nil {
+		respondError(c, err, problemEvaluation)
+		return
+	}
+
+	if err := h.store(c, response, start); err != nil {
+		problem.Respond(c, problemStorage, "failed to store calculation")
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	negotiate.Render(c, http.StatusOK, response)
 }
 
-// Multiplication handler with error handling
-func (h *Handler) Multiplication(c *gin.Context) {
-	var req Request
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// evaluateExpression evaluates src with the user-defined functions of set, nil means none
+func evaluateExpression(src string, set *functions.Set) (Response, error) {
+	tree, err := expression.Parse(src)
+	if err != nil {
+		return Response{}, err
+	}
+	if assign, ok := tree.(*expression.Assign); ok {
+		return Response{}, &expression.EvalError{Position: assign.Pos, Code: problemNoVariables.Code, Message: "variables are only available in sessions"}
 	}
+	var result float64
+	if set != nil {
+		result, err = set.Evaluate(tree, nil)
+	} else {
+		result, err = expression.Evaluate(tree)
+	}
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{
+		Result:     result,
+		Operation:  "expression",
+		Expression: tree.String() + " = " + formatFloat(result),
+		operands:   []string{tree.String()},
+	}, nil
+}
 
-	result := req.Operand1 * req.Operand2
-	expression := formatExpression(req.Operand1, req.Operand2, "*", result)
+// GetRecentCalculations serves both /calculate/recent/:n and /calculate/recent?n=
+func (h *Handler) GetRecentCalculations(c *gin.Context) {
+	n := 5 // default
+	nStr := c.Param("n")
+	if nStr == "" {
+		nStr = c.Query("n")
+	}
+	if nStr != "" {
+		if parsed, err := strconv.Atoi(nStr); err == nil && parsed > 0 && parsed <= 20 {
+			n = parsed
+		}
+	}
 
-	h.Storage.Store(expression)
+	calculations, err := h.Storage.GetRecent(c.Request.Context(), n)
+	if err != nil {
+		logger.LogErrorContext(c.Request.Context(), "Failed to read recent calculations", err)
+		problem.Respond(c, problemStorage, "failed to read calculations")
+		return
+	}
 
-	response := Response{
-		Result:     result,
-		Operation:  "multiplication",
-		Expression: expression,
+	response := RecentResponse{
+		Calculations: make([]string, len(calculations)),
+		Records:      calculations,
+	}
+	for i, calc := range calculations {
+		response.Calculations[i] = calc.String()
 	}
 
-	c.JSON(http.StatusOK, response)
+	negotiate.Render(c, http.StatusOK, response)
 }
 
-// Division handler with error handling
-func (h *Handler) Division(c *gin.Context) {
-	var req Request
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// GetHistory pages through the whole history with filters, newest first unless order=asc
+func (h *Handler) GetHistory(c *gin.Context) {
+	var req HistoryRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		respondBindError(c, err)
 		return
 	}
 
-	if req.Operand2 == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Division by zero is not allowed"})
+	query := storage.Query{
+		From:      req.From,
+		To:        req.To,
+		MinResult: req.MinResult,
+		MaxResult: req.MaxResult,
+		Search:    req.Search,
+		RequestID: req.RequestID,
+		Order:     storage.SortOrder(req.Order),
+		Limit:     req.Limit,
+		Cursor:    req.Cursor,
+	}
+	query.Operations = splitOperations(req.Operation)
+	query, err := checkHistoryQuery(query)
+	if err != nil {
+		problem.Respond(c, problemInvalidQuery, err.Error())
 		return
 	}
 
-	result := req.Operand1 / req.Operand2
-	expression := formatExpression(req.Operand1, req.Operand2, "/", result)
+	page, err := storage.QueryHistory(c.Request.Context(), h.Storage, query)
+	if errors.Is(err, storage.ErrQueryUnsupported) {
+		problem.Respond(c, problemQueryUnsupported, err.Error())
+		return
+	}
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		problem.Respond(c, problemInvalidCursor, err.Error())
+		return
+	}
+	if errors.Is(err, storage.ErrInvalidQuery) {
+		problem.Respond(c, problemInvalidQuery, err.Error())
+		return
+	}
+	if err != nil {
+		logger.LogErrorContext(c.Request.Context(), "Failed to query history", err)
+		problem.Respond(c, problemStorage, "failed to read calculations")
+		return
+	}
 
-	h.Storage.Store(expression)
+	negotiate.Render(c, http.StatusOK, HistoryResponse{
+		Calculations: page.Calculations,
+		NextCursor:   page.NextCursor,
+	})
+}
 
-	response := Response{
-		Result:     result,
-		Operation:  "division",
-		Expression: expression,
+// splitOperations accepts both ?operation=a&operation=b and ?operation=a,b
+func splitOperations(values []string) []string {
+	var operations []string
+	for _, value := range values {
+		for _, op := range strings.Split(value, ",") {
+			if op = strings.TrimSpace(op); op != "" {
+				operations = append(operations, op)
+			}
+		}
 	}
+	return operations
+}
 
-	c.JSON(http.StatusOK, response)
+// checkHistoryQuery applies the default page size and rejects ranges that can never match
+func checkHistoryQuery(query storage.Query) (storage.Query, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultHistoryLimit
+	}
+	if query.Limit < 0 || query.Limit > maxHistoryLimit {
+		return query, errors.New("limit must be between 1 and " + strconv.Itoa(maxHistoryLimit))
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return query, errors.New("from must be before to")
+	}
+	if query.MinResult != nil && query.MaxResult != nil && *query.MinResult > *query.MaxResult {
+		return query, errors.New("min_result must not be greater than max_result")
+	}
+	return query, nil
 }
 
-func (h *Handler) GetRecentCalculations(c *gin.Context) {
-	n := 5 // default
-	if nStr := c.Param("n"); nStr != "" {
-		if parsed, err := strconv.Atoi(nStr); err == nil && parsed > 0 && parsed <= 20 {
-			n = parsed
-		}
+// store turns a successful response into a calculation record
+func (h *Handler) store(c *gin.Context, response Response, start time.Time) error {
+	_, err := h.Storage.Store(c.Request.Context(), response.record(c.ClientIP(), c.Request.UserAgent(), requestid.Get(c), start, time.Since(start)))
+	if err != nil {
+		logger.LogErrorContext(c.Request.Context(), "Failed to store calculation", err, logrus.Fields{"expression": response.Expression})
 	}
+	return err
+}
 
-	calculations := h.Storage.GetRecent(n)
+// evaluate runs eval in its own span and records it with observe, whichever API the evaluation came from
+func evaluate[T any](ctx context.Context, h *Handler, operation string, fallback problem.Type, eval func() (T, error)) (T, error) {
+	operation = h.knownOperation(operation)
+	_, span := tracing.Start(ctx, "evaluate "+operation, attribute.String("calculator.operation", operation))
+	start := time.Now()
+	result, err := eval()
+	h.observe(operation, err, fallback, time.Since(start))
+	tracing.End(span, err)
+	return result, err
+}
 
-	response := RecentResponse{
-		Calculations: calculations,
+// knownOperation is the name of a registered operation or expression, anything else is "unknown":
+// clients must not be able to create metric series or span names
+func (h *Handler) knownOperation(operation string) string {
+	if _, ok := h.Operations.Get(operation); !ok && operation != "expression" {
+		return "unknown"
 	}
+	return operation
+}
 
-	c.JSON(http.StatusOK, response)
+// observe counts an evaluation per operation, failures by the code of their problem (division_by_zero, result_out_of_range...)
+func (h *Handler) observe(operation string, err error, fallback problem.Type, duration time.Duration) {
+	if h.Metrics == nil {
+		return
+	}
+	operation = h.knownOperation(operation)
+	if err != nil {
+		h.Metrics.CountInc("calculation_errors_total", prometheus.Labels{"operation": operation, "kind": problemFor(err, fallback).Code})
+		return
+	}
+	h.Metrics.CountInc("calculations_total", prometheus.Labels{"operation": operation})
+	h.Metrics.Observe("calculation_duration_seconds", prometheus.Labels{"operation": operation}, duration.Seconds())
+}
+
+func (r Response) record(clientIP, userAgent, requestID string, start time.Time, duration time.Duration) storage.Calculation {
+	result := r.ExactResult
+	if result == "" {
+		result = formatFloat(r.Result)
+	}
+	operands := r.operands
+	if operands == nil {
+		operands = []string{}
+	}
+	return storage.Calculation{
+		Timestamp:  start.UTC(),
+		Operation:  r.Operation,
+		Operands:   operands,
+		Result:     result,
+		Expression: r.Expression,
+		ClientIP:   clientIP,
+		UserAgent:  userAgent,
+		RequestID:  requestID,
+		Duration:   duration,
+	}
 }
 
-func formatExpression(a, b float64, operator string, result float64) string {
-	return strconv.FormatFloat(a, 'f', -1, 64) + " " + operator + " " +
-		strconv.FormatFloat(b, 'f', -1, 64) + " = " +
-		strconv.FormatFloat(result, 'f', -1, 64)
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
 }
@@ -0,0 +1,91 @@
+package calculator
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"CalculatorWebService/calculator/storage"
+	"CalculatorWebService/internal/config"
+	"CalculatorWebService/internal/health"
+)
+
+// Probe statuses
+const (
+	statusAlive        = "alive"
+	statusReady        = "ready"
+	statusNotReady     = "not_ready"
+	statusShuttingDown = "shutting_down"
+)
+
+// HealthResponse is the body of both probes, only /readyz has the checks
+type HealthResponse struct {
+	Status        string                   `json:"status"` // alive, ready, not_ready or shutting_down
+	Timestamp     time.Time                `json:"timestamp"`
+	Service       string                   `json:"service"`
+	Version       string                   `json:"version"`
+	StartedAt     time.Time                `json:"started_at"`
+	Uptime        string                   `json:"uptime"`
+	UptimeSeconds int64                    `json:"uptime_seconds"`
+	Build         health.BuildInfo         `json:"build"`
+	Checks        map[string]health.Result `json:"checks,omitempty"`
+}
+
+func (s *Service) healthResponse(status string) HealthResponse {
+	now := time.Now().UTC()
+	uptime := now.Sub(s.started)
+	return HealthResponse{
+		Status:        status,
+		Timestamp:     now,
+		Service:       "calculator",
+		Version:       s.config.Version,
+		StartedAt:     s.started,
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+		Build:         s.build,
+	}
+}
+
+// Livez answers as long as the process serves HTTP. It doesn't look at the dependencies on purpose:
+// a broken storage is a reason to stop sending traffic, restarting the process won't fix it.
+func (s *Service) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, s.healthResponse(statusAlive))
+}
+
+// Readyz runs the dependency checks and answers 503 when one fails or the service is shutting down,
+// the body is the same either way so the failing check can be seen in the probe output
+func (s *Service) Readyz(c *gin.Context) {
+	if s.health.ShuttingDown() {
+		c.JSON(http.StatusServiceUnavailable, s.healthResponse(statusShuttingDown))
+		return
+	}
+	checks, ok := s.health.Run(c.Request.Context())
+	status, code := statusReady, http.StatusOK
+	if !ok {
+		status, code = statusNotReady, http.StatusServiceUnavailable
+	}
+	response := s.healthResponse(status)
+	response.Checks = checks
+	c.JSON(code, response)
+}
+
+// registerChecks adds the readiness checks of the backend. They call the backend directly,
+// probes are neither traced nor published.
+func registerChecks(registry *health.Registry, backend storage.Storage, serviceConfig config.CalculatorConfig) {
+	registry.Register("storage", func(ctx context.Context) (string, error) {
+		_, err := backend.GetRecent(ctx, 1)
+		return serviceConfig.StorageType, err
+	})
+	if checker, ok := backend.(storage.HealthChecker); ok {
+		registry.Register("storage_writable", func(ctx context.Context) (string, error) {
+			return "", checker.CheckHealth(ctx)
+		})
+	}
+	if file, ok := backend.(*storage.FileStorage); ok {
+		minFree := uint64(serviceConfig.HealthMinFreeMB) << 20
+		registry.Register("disk_space", health.DiskSpace(filepath.Dir(file.Path()), minFree))
+	}
+}
@@ -0,0 +1,79 @@
+package calculator
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"CalculatorWebService/calculator/precise"
+	"CalculatorWebService/internal/openapi"
+)
+
+// Number is an operand that keeps its literal form, so precise mode does not lose digits to float64.
+// It accepts both JSON numbers and decimal strings: 0.1 and "0.1" are the same operand.
+type Number string
+
+func (n *Number) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*n = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*n = Number(strings.TrimSpace(s))
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return newDomainError(problemInvalidOperand, "operand must be a number or a decimal string")
+	}
+	*n = Number(num)
+	return nil
+}
+
+// String returns the operand as the client sent it, a missing operand counts as zero.
+func (n Number) String() string {
+	if n == "" {
+		return "0"
+	}
+	return string(n)
+}
+
+// Float64 parses the operand for the float mode, a missing operand counts as zero.
+func (n Number) Float64() (float64, error) {
+	if n == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, newDomainError(problemInvalidOperand, "invalid operand '"+string(n)+"'")
+	}
+	return value, nil
+}
+
+// Rat parses the operand exactly for the precise mode, a missing operand counts as zero.
+func (n Number) Rat() (*big.Rat, error) {
+	if n == "" {
+		return new(big.Rat), nil
+	}
+	value, err := precise.Parse(string(n))
+	if err != nil {
+		return nil, newDomainError(problemInvalidOperand, "invalid operand '"+string(n)+"'")
+	}
+	return value, nil
+}
+
+// OpenAPISchema documents that both JSON numbers and decimal strings are accepted
+func (n Number) OpenAPISchema() *openapi.Schema {
+	return &openapi.Schema{
+		OneOf:       []*openapi.Schema{{Type: "number"}, {Type: "string", Format: "decimal"}},
+		Description: "a JSON number or a decimal string, strings keep every digit for the precise mode",
+	}
+}
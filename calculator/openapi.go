@@ -0,0 +1,139 @@
+package calculator
+
+import (
+	"net/http"
+	"strings"
+
+	"CalculatorWebService/calculator/functions"
+	"CalculatorWebService/calculator/session"
+	"CalculatorWebService/internal/negotiate"
+	"CalculatorWebService/internal/openapi"
+	"CalculatorWebService/internal/problem"
+)
+
+const (
+	openAPIPath = "/openapi.json"
+	docsPath    = "/docs"
+)
+
+// newAPIDocument describes the routes of apiRoutes
+func newAPIDocument(routes []openapi.Route, version string) *openapi.Document {
+	b := openapi.NewBuilder(openapi.Info{
+		Title:       "Calculator API",
+		Description: "Arithmetic operations, expressions, sessions and user-defined functions with a calculation history.",
+		Version:     version,
+	}, problem.Problem{}, problem.ContentType)
+	for _, route := range routes {
+		b.Add(negotiated(route))
+	}
+	return b.Document()
+}
+
+// apiRoutes describes every route of setupRoutes, TestAPIDocumentDescribesEveryRoute makes sure none is forgotten.
+// The response types also decide which formats a route can be asked for, see setupRoutes.
+func apiRoutes(operations *Registry) []openapi.Route {
+	var routes []openapi.Route
+	for _, op := range operations.List() {
+		route := openapi.Route{
+			Method:   http.MethodPost,
+			Path:     "/calculate/" + op.Path,
+			Summary:  op.Name + " (" + op.Symbol + ")",
+			Tags:     []string{"calculate"},
+			Body:     Request{},
+			Response: Response{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}
+		if op.Arity == 1 {
+			route.Body = UnaryRequest{}
+		}
+		routes = append(routes, route)
+	}
+
+	return append(routes, []openapi.Route{
+		{Method: http.MethodPost, Path: "/calculate/expression", Summary: "Evaluate a free-form expression", Tags: []string{"calculate"},
+			Body: ExpressionRequest{}, Response: Response{}, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError}},
+		{Method: http.MethodPost, Path: "/calculate/batch", Summary: "Evaluate many operations, a bare array of items is accepted too", Tags: []string{"calculate"},
+			Body: BatchRequest{}, Response: BatchResponse{}, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError}},
+		{Method: http.MethodGet, Path: "/calculate/operations", Summary: "List the registered operations", Tags: []string{"calculate"},
+			Response: OperationsResponse{}},
+		{Method: http.MethodGet, Path: "/calculate/recent", Summary: "Last n calculations (default 5, max 20)", Tags: []string{"history"},
+			Query: struct {
+				N int `form:"n"`
+			}{}, Response: RecentResponse{}, Errors: []int{http.StatusInternalServerError}},
+		{Method: http.MethodGet, Path: "/calculate/recent/:n", Summary: "Last n calculations (default 5, max 20)", Tags: []string{"history"},
+			Response: RecentResponse{}, Errors: []int{http.StatusInternalServerError}},
+		{Method: http.MethodGet, Path: "/calculate/history", Summary: "Search and page through the history", Tags: []string{"history"},
+			Query: HistoryRequest{}, Response: HistoryResponse{}, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusNotImplemented}},
+		{Method: http.MethodGet, Path: "/calculate/stream", Summary: "Live feed of calculations as Server-Sent Events", Tags: []string{"history"},
+			Headers: []string{"Last-Event-ID"}, Query: StreamRequest{}, ContentType: "text/event-stream", Errors: []int{http.StatusBadRequest}},
+		{Method: http.MethodGet, Path: "/calculate/ws", Summary: "Live feed of calculations over a WebSocket, messages are StreamMessage objects", Tags: []string{"history"},
+			Query: StreamRequest{}, Status: http.StatusSwitchingProtocols, Errors: []int{http.StatusBadRequest}},
+
+		{Method: http.MethodPost, Path: "/sessions", Summary: "Start an interactive session", Tags: []string{"sessions"},
+			Response: session.State{}, Status: http.StatusCreated, Errors: []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable}},
+		{Method: http.MethodGet, Path: "/sessions/:id", Summary: "Session state", Tags: []string{"sessions"},
+			Response: session.State{}, Errors: []int{http.StatusNotFound}},
+		{Method: http.MethodDelete, Path: "/sessions/:id", Summary: "End the session", Tags: []string{"sessions"},
+			Status: http.StatusNoContent, Errors: []int{http.StatusNotFound}},
+		{Method: http.MethodPost, Path: "/sessions/:id/evaluate", Summary: "Evaluate an expression or an assignment in the session", Tags: []string{"sessions"},
+			Body: SessionEvaluateRequest{}, Response: session.Result{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodPost, Path: "/sessions/:id/memory", Summary: "Apply a memory key: M+, M-, MR or MC", Tags: []string{"sessions"},
+			Body: MemoryRequest{}, Response: MemoryResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodGet, Path: "/sessions/:id/history", Summary: "Evaluations made in the session", Tags: []string{"sessions"},
+			Response: SessionHistoryResponse{}, Errors: []int{http.StatusNotFound}},
+		{Method: http.MethodPost, Path: "/sessions/:id/functions", Summary: "Define a function in the session", Tags: []string{"sessions", "functions"},
+			Body: DefineFunctionRequest{}, Response: functions.Definition{}, Status: http.StatusCreated,
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity}},
+		{Method: http.MethodGet, Path: "/sessions/:id/functions", Summary: "Functions of the session", Tags: []string{"sessions", "functions"},
+			Response: FunctionsResponse{}, Errors: []int{http.StatusNotFound}},
+		{Method: http.MethodDelete, Path: "/sessions/:id/functions/:name", Summary: "Delete a function of the session", Tags: []string{"sessions", "functions"},
+			Status: http.StatusNoContent, Errors: []int{http.StatusNotFound}},
+
+		{Method: http.MethodPost, Path: "/functions", Summary: "Define a function of the tenant", Tags: []string{"functions"},
+			Headers: []string{tenantHeader}, Body: DefineFunctionRequest{}, Response: functions.Definition{}, Status: http.StatusCreated,
+			Errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError}},
+		{Method: http.MethodGet, Path: "/functions", Summary: "Functions of the tenant", Tags: []string{"functions"},
+			Headers: []string{tenantHeader}, Response: FunctionsResponse{}, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError}},
+		{Method: http.MethodDelete, Path: "/functions/:name", Summary: "Delete a function of the tenant", Tags: []string{"functions"},
+			Headers: []string{tenantHeader}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError}},
+		{Method: http.MethodPost, Path: "/functions/evaluate", Summary: "Evaluate an expression calling the tenant's functions", Tags: []string{"functions"},
+			Headers: []string{tenantHeader}, Body: ExpressionRequest{}, Response: Response{}, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError}},
+
+		{Method: http.MethodGet, Path: "/metrics", Summary: "Prometheus metrics", Tags: []string{"operations"},
+			ContentType: "text/plain"},
+		{Method: http.MethodGet, Path: "/livez", Summary: "Liveness probe, up as long as the process serves requests", Tags: []string{"operations"},
+			Response: HealthResponse{}},
+		{Method: http.MethodGet, Path: "/readyz", Summary: "Readiness probe with the dependency checks, 503 with the same body when not ready", Tags: []string{"operations"},
+			Response: HealthResponse{}},
+		{Method: http.MethodGet, Path: "/health", Summary: "Same as /livez, kept for older clients", Tags: []string{"operations"},
+			Response: HealthResponse{}},
+		{Method: http.MethodGet, Path: "/problems", Summary: "Catalog of the error types, codes are stable", Tags: []string{"operations"},
+			Response: problem.TypesResponse{}},
+		{Method: http.MethodGet, Path: "/problems/:code", Summary: "A single error type, type URIs of error bodies lead here", Tags: []string{"operations"},
+			Response: problem.Type{}, Errors: []int{http.StatusNotFound}},
+		{Method: http.MethodGet, Path: openAPIPath, Summary: "This document", Tags: []string{"operations"},
+			Response: map[string]interface{}{}},
+		{Method: http.MethodGet, Path: docsPath, Summary: "Swagger UI for this document", Tags: []string{"operations"},
+			ContentType: "text/html"},
+	}...)
+}
+
+// negotiated adds the media types of routes served through the negotiate package,
+// those are the API routes except the live feeds which have a ContentType or no response.
+// They are also the routes of the rate limit.
+func negotiated(route openapi.Route) openapi.Route {
+	api := strings.HasPrefix(route.Path, "/calculate/") || strings.HasPrefix(route.Path, "/sessions") || strings.HasPrefix(route.Path, "/functions")
+	if !api || route.ContentType != "" {
+		return route
+	}
+	errors := append([]int{}, route.Errors...)
+	if route.Body != nil {
+		route.Consumes = negotiate.Consumes
+		errors = append(errors, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType)
+	}
+	if route.Response != nil {
+		route.Produces = negotiate.Produces(route.Response)
+	}
+	route.Errors = append(errors, http.StatusNotAcceptable, http.StatusTooManyRequests)
+	return route
+}
@@ -0,0 +1,47 @@
+package calculator
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"CalculatorWebService/internal/openapi"
+)
+
+// undocumented returns the registered routes the document doesn't describe
+func undocumented(doc *openapi.Document, routes gin.RoutesInfo) []string {
+	var missing []string
+	for _, route := range routes {
+		if !doc.Has(route.Method, route.Path) {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Every route of setupRoutes has to be described in newAPIDocument, so the document can't silently fall behind
+func TestAPIDocumentDescribesEveryRoute(t *testing.T) {
+	s := newTestService(t)
+	defer s.Shutdown(context.Background())
+
+	if missing := undocumented(s.api, s.router.Routes()); len(missing) > 0 {
+		t.Errorf("routes missing from the OpenAPI document in calculator/openapi.go: %v", missing)
+	}
+}
+
+func TestAPIDocumentReportsUndocumentedRoute(t *testing.T) {
+	s := newTestService(t)
+	defer s.Shutdown(context.Background())
+
+	s.router.GET("/undocumented/:id", func(c *gin.Context) {})
+	s.router.DELETE("/calculate/history", func(c *gin.Context) {})
+	want := []string{http.MethodDelete + " /calculate/history", http.MethodGet + " /undocumented/:id"}
+	if missing := undocumented(s.api, s.router.Routes()); !reflect.DeepEqual(missing, want) {
+		t.Errorf("undocumented routes = %v, want %v", missing, want)
+	}
+}
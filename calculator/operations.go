@@ -0,0 +1,327 @@
+package calculator
+
+import (
+	"math"
+	"strings"
+
+	"CalculatorWebService/calculator/precise"
+)
+
+// Domain errors are returned as 400 to the client, same as the division by zero check.
+// Each has a problem type, so clients can tell them apart by code.
+var (
+	errDivisionByZero   = newDomainError(problemDivisionByZero, "Division by zero is not allowed")
+	errModuloByZero     = newDomainError(problemModuloByZero, "Modulo by zero is not allowed")
+	errNotInteger       = newDomainError(problemIntegerRequired, "Integer modulo requires integer operands")
+	errZeroPowerNeg     = newDomainError(problemUndefinedPower, "Zero cannot be raised to a negative power")
+	errNegativeBase     = newDomainError(problemUndefinedPower, "Negative base requires an integer exponent")
+	errZeroRootDegree   = newDomainError(problemZeroRootDegree, "Root degree cannot be zero")
+	errEvenRootNegative = newDomainError(problemUndefinedRoot, "Even or fractional root of a negative number is not defined")
+	errLogNonPositive   = newDomainError(problemLogarithmDomain, "Logarithm is only defined for positive numbers")
+	errLogBase          = newDomainError(problemLogarithmDomain, "Logarithm base must be positive and not equal to 1")
+	errTangentUndefined = newDomainError(problemTangentUndefined, "Tangent is not defined for this angle")
+	errInverseTrigRange = newDomainError(problemInverseTrigDomain, "Operand must be between -1 and 1")
+	errOutOfRange       = newDomainError(problemOutOfRange, "Result is out of range")
+	errInvalidUnit      = newDomainError(problemInvalidUnit, "Unit must be either 'radians' or 'degrees'")
+)
+
+// largest integer a float64 holds exactly, integer modulo beyond it would be meaningless
+const maxExactInteger = 1 << 53
+
+type AngleUnit string
+
+const (
+	Radians AngleUnit = "radians"
+	Degrees AngleUnit = "degrees"
+)
+
+func parseAngleUnit(unit string) (AngleUnit, error) {
+	switch strings.ToLower(unit) {
+	case "", "rad", "radians":
+		return Radians, nil
+	case "deg", "degrees":
+		return Degrees, nil
+	default:
+		return "", errInvalidUnit
+	}
+}
+
+// builtinOperations is the catalogue served by the default registry.
+// Validators hold every domain check, so evaluators can stay plain math.
+func builtinOperations() []Operation {
+	return []Operation{
+		{
+			Name: "addition", Path: "addition", Symbol: "+", Notation: Infix, Arity: 2,
+			Evaluate: func(x []float64, _ AngleUnit) float64 { return x[0] + x[1] },
+			Precise:  precise.Add,
+		},
+		{
+			Name: "subtraction", Path: "subtraction", Symbol: "-", Notation: Infix, Arity: 2,
+			Evaluate: func(x []float64, _ AngleUnit) float64 { return x[0] - x[1] },
+			Precise:  precise.Sub,
+		},
+		{
+			Name: "multiplication", Path: "multiplication", Symbol: "*", Notation: Infix, Arity: 2,
+			Evaluate: func(x []float64, _ AngleUnit) float64 { return x[0] * x[1] },
+			Precise:  precise.Mul,
+		},
+		{
+			Name: "division", Path: "division", Symbol: "/", Notation: Infix, Arity: 2,
+			Constraints: []string{"operand2 must not be zero"},
+			Validate:    nonZeroDivisor(errDivisionByZero),
+			Evaluate:    func(x []float64, _ AngleUnit) float64 { return x[0] / x[1] },
+			Precise:     precise.Quo,
+		},
+		{
+			Name: "power", Path: "power", Symbol: "^", Notation: Infix, Arity: 2,
+			Constraints: []string{"zero base requires a non-negative exponent", "negative base requires an integer exponent"},
+			Validate:    validatePower,
+			Evaluate:    func(x []float64, _ AngleUnit) float64 { return math.Pow(x[0], x[1]) },
+		},
+		{
+			Name: "modulo", Path: "modulo", Symbol: "%", Notation: Infix, Arity: 2,
+			Constraints: []string{"operand2 must not be zero", "result has the sign of operand1"},
+			Validate:    nonZeroDivisor(errModuloByZero),
+			Evaluate:    func(x []float64, _ AngleUnit) float64 { return math.Mod(x[0], x[1]) },
+		},
+		{
+			Name: "integer_modulo", Path: "integer-modulo", Symbol: "mod", Notation: Infix, Arity: 2,
+			Constraints: []string{"operands must be integers up to 2^53", "operand2 must not be zero"},
+			Validate:    validateIntegerModulo,
+			Evaluate:    func(x []float64, _ AngleUnit) float64 { return float64(int64(x[0]) % int64(x[1])) },
+		},
+		{
+			Name: "root", Path: "root", Symbol: "root", Notation: Prefix, Arity: 2,
+			Constraints: []string{"operand2 (degree) must not be zero", "negative operand1 requires an odd integer degree"},
+			Validate:    validateRoot,
+			Evaluate:    func(x []float64, _ AngleUnit) float64 { return root(x[0], x[1]) },
+		},
+		{
+			Name: "logarithm", Path: "logarithm", Symbol: "log", Notation: Prefix, Arity: 2,
+			Constraints: []string{"operand1 must be positive", "operand2 (base) must be positive and not equal to 1"},
+			Validate:    validateLogarithm,
+			Evaluate:    func(x []float64, _ AngleUnit) float64 { return math.Log(x[0]) / math.Log(x[1]) },
+		},
+		{
+			Name: "natural_logarithm", Path: "ln", Symbol: "ln", Notation: Prefix, Arity: 1,
+			Constraints: []string{"operand must be positive"},
+			Validate:    positiveOperand,
+			Evaluate:    unary(math.Log),
+		},
+		{
+			Name: "exponent", Path: "exponent", Symbol: "exp", Notation: Prefix, Arity: 1,
+			Evaluate: unary(math.Exp),
+		},
+		{
+			Name: "sine", Path: "sin", Symbol: "sin", Notation: Prefix, Arity: 1, Angular: true,
+			Evaluate: sine,
+		},
+		{
+			Name: "cosine", Path: "cos", Symbol: "cos", Notation: Prefix, Arity: 1, Angular: true,
+			Evaluate: cosine,
+		},
+		{
+			Name: "tangent", Path: "tan", Symbol: "tan", Notation: Prefix, Arity: 1, Angular: true,
+			Constraints: []string{"undefined for odd multiples of 90 degrees"},
+			Validate:    validateTangent,
+			Evaluate:    tangent,
+		},
+		{
+			Name: "arcsine", Path: "asin", Symbol: "asin", Notation: Prefix, Arity: 1, Angular: true,
+			Constraints: []string{"operand must be between -1 and 1"},
+			Validate:    unitInterval,
+			Evaluate:    func(x []float64, unit AngleUnit) float64 { return toUnit(math.Asin(x[0]), unit) },
+		},
+		{
+			Name: "arccosine", Path: "acos", Symbol: "acos", Notation: Prefix, Arity: 1, Angular: true,
+			Constraints: []string{"operand must be between -1 and 1"},
+			Validate:    unitInterval,
+			Evaluate:    func(x []float64, unit AngleUnit) float64 { return toUnit(math.Acos(x[0]), unit) },
+		},
+		{
+			Name: "arctangent", Path: "atan", Symbol: "atan", Notation: Prefix, Arity: 1, Angular: true,
+			Evaluate: func(x []float64, unit AngleUnit) float64 { return toUnit(math.Atan(x[0]), unit) },
+		},
+		{
+			Name: "floor", Path: "floor", Symbol: "floor", Notation: Prefix, Arity: 1,
+			Evaluate: unary(math.Floor),
+		},
+		{
+			Name: "ceil", Path: "ceil", Symbol: "ceil", Notation: Prefix, Arity: 1,
+			Evaluate: unary(math.Ceil),
+		},
+		{
+			Name: "round", Path: "round", Symbol: "round", Notation: Prefix, Arity: 1,
+			Constraints: []string{"halves are rounded away from zero"},
+			Evaluate:    unary(math.Round),
+		},
+		{
+			Name: "absolute", Path: "absolute", Symbol: "abs", Notation: Prefix, Arity: 1,
+			Evaluate: unary(math.Abs),
+		},
+	}
+}
+
+// unary adapts functions that don't care about angle units
+func unary(fn func(float64) float64) func([]float64, AngleUnit) float64 {
+	return func(x []float64, _ AngleUnit) float64 {
+		return fn(x[0])
+	}
+}
+
+func nonZeroDivisor(err error) func([]float64, AngleUnit) error {
+	return func(x []float64, _ AngleUnit) error {
+		if x[1] == 0 {
+			return err
+		}
+		return nil
+	}
+}
+
+func validatePower(x []float64, _ AngleUnit) error {
+	if x[0] == 0 && x[1] < 0 {
+		return errZeroPowerNeg
+	}
+	if x[0] < 0 && x[1] != math.Trunc(x[1]) {
+		return errNegativeBase
+	}
+	return nil
+}
+
+func validateIntegerModulo(x []float64, _ AngleUnit) error {
+	for _, v := range x {
+		if v != math.Trunc(v) || math.Abs(v) > maxExactInteger {
+			return errNotInteger
+		}
+	}
+	if x[1] == 0 {
+		return errModuloByZero
+	}
+	return nil
+}
+
+func validateRoot(x []float64, _ AngleUnit) error {
+	value, degree := x[0], x[1]
+	if degree == 0 {
+		return errZeroRootDegree
+	}
+	if value < 0 && (degree != math.Trunc(degree) || math.Mod(degree, 2) == 0) {
+		return errEvenRootNegative
+	}
+	if value == 0 && degree < 0 {
+		return errZeroPowerNeg
+	}
+	return nil
+}
+
+func validateLogarithm(x []float64, _ AngleUnit) error {
+	if x[0] <= 0 {
+		return errLogNonPositive
+	}
+	if x[1] <= 0 || x[1] == 1 {
+		return errLogBase
+	}
+	return nil
+}
+
+func positiveOperand(x []float64, _ AngleUnit) error {
+	if x[0] <= 0 {
+		return errLogNonPositive
+	}
+	return nil
+}
+
+func unitInterval(x []float64, _ AngleUnit) error {
+	if x[0] < -1 || x[0] > 1 {
+		return errInverseTrigRange
+	}
+	return nil
+}
+
+func validateTangent(x []float64, unit AngleUnit) error {
+	if unit == Degrees {
+		if d := normalizeDegrees(x[0]); d == 90 || d == 270 {
+			return errTangentUndefined
+		}
+	}
+	return nil
+}
+
+// root returns the n-th root of x, odd integer roots of negative numbers are allowed
+func root(x, n float64) float64 {
+	switch {
+	case n == 2:
+		return math.Sqrt(x)
+	case n == 3:
+		// Cbrt is exact for perfect cubes, Pow(x, 1/3) is not
+		return math.Cbrt(x)
+	case x < 0:
+		return -math.Pow(-x, 1/n)
+	default:
+		return math.Pow(x, 1/n)
+	}
+}
+
+func sine(x []float64, unit AngleUnit) float64 {
+	if unit == Degrees {
+		// exact values for multiples of 90 degrees, sin(180°) should be 0 and not 1.2e-16
+		switch normalizeDegrees(x[0]) {
+		case 0, 180:
+			return 0
+		case 90:
+			return 1
+		case 270:
+			return -1
+		}
+		return math.Sin(x[0] * math.Pi / 180)
+	}
+	return math.Sin(x[0])
+}
+
+func cosine(x []float64, unit AngleUnit) float64 {
+	if unit == Degrees {
+		switch normalizeDegrees(x[0]) {
+		case 90, 270:
+			return 0
+		case 0:
+			return 1
+		case 180:
+			return -1
+		}
+		return math.Cos(x[0] * math.Pi / 180)
+	}
+	return math.Cos(x[0])
+}
+
+func tangent(x []float64, unit AngleUnit) float64 {
+	if unit == Degrees {
+		switch normalizeDegrees(x[0]) {
+		case 0, 180:
+			return 0
+		}
+		return math.Tan(x[0] * math.Pi / 180)
+	}
+	return math.Tan(x[0])
+}
+
+func normalizeDegrees(x float64) float64 {
+	x = math.Mod(x, 360)
+	if x < 0 {
+		x += 360
+	}
+	return x
+}
+
+func toUnit(rad float64, unit AngleUnit) float64 {
+	if unit == Degrees {
+		return rad * 180 / math.Pi
+	}
+	return rad
+}
+
+func finite(x float64) (float64, error) {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return 0, errOutOfRange
+	}
+	return x, nil
+}
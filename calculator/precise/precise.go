@@ -0,0 +1,131 @@
+// Package precise implements arbitrary-precision arithmetic for the calculator.
+// Operands are kept as big.Rat so that decimal literals like 0.1 are exact,
+// results are rounded to the requested number of significant digits only when formatted.
+package precise
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxDigits caps the number of significant digits a caller can ask for.
+// Formatting cost grows with the digit count, so it has to be bounded.
+const MaxDigits = 1000
+
+var (
+	ErrDivisionByZero = errors.New("division by zero is not allowed")
+	ErrInvalidNumber  = errors.New("invalid decimal number")
+)
+
+// Parse reads a decimal literal like "0.1", "-12.5" or "1e-3".
+func Parse(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	// ParseFloat rejects fractions ("1/3"), while big.Rat rejects inf/nan, so both are required
+	// out-of-range literals (1e400) are fine here, only the syntax matters
+	if _, err := strconv.ParseFloat(s, 64); err != nil && !errors.Is(err, strconv.ErrRange) {
+		return nil, ErrInvalidNumber
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, ErrInvalidNumber
+	}
+	return r, nil
+}
+
+func Add(a, b *big.Rat) (*big.Rat, error) {
+	return new(big.Rat).Add(a, b), nil
+}
+
+func Sub(a, b *big.Rat) (*big.Rat, error) {
+	return new(big.Rat).Sub(a, b), nil
+}
+
+func Mul(a, b *big.Rat) (*big.Rat, error) {
+	return new(big.Rat).Mul(a, b), nil
+}
+
+func Quo(a, b *big.Rat) (*big.Rat, error) {
+	if b.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	return new(big.Rat).Quo(a, b), nil
+}
+
+// Format renders r as a plain decimal string (no exponent) rounded to the given
+// number of significant digits, halves away from zero. Trailing zeros are trimmed,
+// so exact results like 0.1 + 0.2 come back as "0.3".
+func Format(r *big.Rat, digits int) string {
+	if r.Sign() == 0 {
+		return "0"
+	}
+	if digits < 1 {
+		digits = 1
+	}
+
+	abs := new(big.Rat).Abs(r)
+	exponent := decimalExponent(abs)
+	scale := digits - 1 - exponent
+
+	var out string
+	if exponent > MaxDigits || exponent < -MaxDigits {
+		// a plain decimal would be thousands of zeros long, scientific notation is the only sane option here
+		out = new(big.Float).SetPrec(uint(digits)*4+64).SetRat(abs).Text('e', digits-1)
+	} else if scale >= 0 {
+		out = trimZeros(abs.FloatString(scale))
+	} else {
+		// rounding happens left of the decimal point: round abs / 10^-scale and pad with zeros
+		unit := new(big.Rat).SetInt(pow10(-scale))
+		rounded := new(big.Rat).Quo(abs, unit).FloatString(0)
+		if rounded == "0" {
+			return "0"
+		}
+		out = rounded + strings.Repeat("0", -scale)
+	}
+
+	if r.Sign() < 0 {
+		return "-" + out
+	}
+	return out
+}
+
+// decimalExponent returns floor(log10(r)) for a positive r.
+func decimalExponent(r *big.Rat) int {
+	mant := new(big.Float)
+	exp := new(big.Float).SetRat(r).MantExp(mant)
+	m, _ := mant.Float64()
+	estimate := int(math.Floor((float64(exp) + math.Log2(m)) * math.Log10(2)))
+
+	// the float estimate can be off by one around exact powers of ten
+	for cmpPow10(r, estimate) < 0 {
+		estimate--
+	}
+	for cmpPow10(r, estimate+1) >= 0 {
+		estimate++
+	}
+	return estimate
+}
+
+func cmpPow10(r *big.Rat, exp int) int {
+	p := new(big.Rat)
+	if exp >= 0 {
+		p.SetInt(pow10(exp))
+	} else {
+		p.SetFrac(big.NewInt(1), pow10(-exp))
+	}
+	return r.Cmp(p)
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func trimZeros(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
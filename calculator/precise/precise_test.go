@@ -0,0 +1,141 @@
+package precise
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) *big.Rat {
+	t.Helper()
+	r, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", s, err)
+	}
+	return r
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string // exact fraction
+		wantErr bool
+	}{
+		{in: "0.1", want: "1/10"},
+		{in: " -12.5 ", want: "-25/2"},
+		{in: "1e-3", want: "1/1000"},
+		{in: "1e400", want: "1" + strings.Repeat("0", 400) + "/1"},
+		{in: "1/3", wantErr: true},
+		{in: "inf", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "0x10", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidNumber) {
+					t.Errorf("Parse(%q) = %v, %v, want ErrInvalidNumber", tt.in, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.in, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperations(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     func(a, b *big.Rat) (*big.Rat, error)
+		a, b   string
+		digits int
+		want   string
+	}{
+		{"add is exact", Add, "0.1", "0.2", 20, "0.3"},
+		{"sub", Sub, "1", "0.9", 20, "0.1"},
+		{"mul", Mul, "1.1", "1.1", 20, "1.21"},
+		{"quo rounds to digits", Quo, "1", "3", 10, "0.3333333333"},
+		{"quo rounds up", Quo, "2", "3", 5, "0.66667"},
+		{"negative rounding", Quo, "-2", "3", 5, "-0.66667"},
+		{"large exact", Add, "12345678901234567890", "1", 30, "12345678901234567891"},
+		{"rounding left of the point", Add, "123456", "0", 3, "123000"},
+		{"carry into a new digit", Add, "9.9996", "0", 4, "10"},
+		{"beyond float64", Add, "1e400", "1", 5, "1" + strings.Repeat("0", 400)},
+		{"beyond MaxDigits", Add, "1e1200", "1", 5, "1.0000e+1200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.op(mustParse(t, tt.a), mustParse(t, tt.b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Format(result, tt.digits); got != tt.want {
+				t.Errorf("Format(%s, %d) = %s, want %s", result.RatString(), tt.digits, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoByZero(t *testing.T) {
+	if _, err := Quo(mustParse(t, "1"), mustParse(t, "0")); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Quo(1, 0) error = %v, want ErrDivisionByZero", err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		in     string
+		digits int
+		want   string
+	}{
+		{"0", 10, "0"},
+		{"1000", 10, "1000"},
+		{"0.000123456", 3, "0.000123"},
+		{"0.1", 0, "0.1"}, // at least one digit
+		{"0.4", 0, "0.4"},
+		{"99.95", 3, "100"},
+		{"0.125", 2, "0.13"}, // halves away from zero
+		{"-0.125", 2, "-0.13"},
+		{"0.00049", 1, "0.0005"},
+		{"1e-1200", 3, "1.00e-1200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Format(mustParse(t, tt.in), tt.digits); got != tt.want {
+				t.Errorf("Format(%s, %d) = %s, want %s", tt.in, tt.digits, got, tt.want)
+			}
+		})
+	}
+}
+
+// decimalExponent is estimated with floats and corrected, exact powers of ten are the tricky part
+func TestDecimalExponent(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"1", 0},
+		{"9.999", 0},
+		{"10", 1},
+		{"0.1", -1},
+		{"0.0999", -2},
+		{"1e300", 300},
+		{"1e-300", -300},
+		{"1e400", 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := decimalExponent(mustParse(t, tt.in)); got != tt.want {
+				t.Errorf("decimalExponent(%s) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package calculator
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"CalculatorWebService/calculator/expression"
+	"CalculatorWebService/calculator/session"
+	"CalculatorWebService/internal/problem"
+)
+
+// Problem types of the calculator API. Codes are part of the API contract, never rename one.
+var (
+	problemInvalidExpression = problem.NewType("invalid_expression", http.StatusBadRequest, "Expression is not valid")
+	problemEvaluation        = problem.NewType(expression.CodeEvaluation, http.StatusBadRequest, "Expression could not be evaluated")
+	problemDivisionByZero    = problem.NewType(expression.CodeDivisionByZero, http.StatusBadRequest, "Division by zero")
+	problemModuloByZero      = problem.NewType(expression.CodeModuloByZero, http.StatusBadRequest, "Modulo by zero")
+	problemNotFinite         = problem.NewType(expression.CodeNotFinite, http.StatusBadRequest, "Result is not a finite number")
+	problemUnknownVariable   = problem.NewType(expression.CodeUnknownVariable, http.StatusBadRequest, "Unknown variable")
+	problemUnknownFunction   = problem.NewType(expression.CodeUnknownFunction, http.StatusBadRequest, "Unknown function")
+	problemArgumentCount     = problem.NewType(expression.CodeArgumentCount, http.StatusBadRequest, "Wrong number of arguments")
+	problemCallDepth         = problem.NewType(expression.CodeCallDepthExceeded, http.StatusBadRequest, "Function calls nested too deep")
+	problemStepLimit         = problem.NewType(expression.CodeStepLimitExceeded, http.StatusBadRequest, "Evaluation takes too many steps")
+	problemReservedVariable  = problem.NewType(session.CodeReservedVariable, http.StatusBadRequest, "Variable name is reserved")
+	problemTooManyVariables  = problem.NewType(session.CodeTooManyVariables, http.StatusBadRequest, "Too many variables in the session")
+	problemNoVariables       = problem.NewType("variables_unavailable", http.StatusBadRequest, "Variables are only available in sessions")
+
+	problemInvalidOperand    = problem.NewType("invalid_operand", http.StatusBadRequest, "Operand is not a number")
+	problemIntegerRequired   = problem.NewType("integer_required", http.StatusBadRequest, "Operands must be integers")
+	problemUndefinedPower    = problem.NewType("undefined_power", http.StatusBadRequest, "Power is not defined for these operands")
+	problemZeroRootDegree    = problem.NewType("zero_root_degree", http.StatusBadRequest, "Root degree cannot be zero")
+	problemUndefinedRoot     = problem.NewType("undefined_root", http.StatusBadRequest, "Root is not defined for these operands")
+	problemLogarithmDomain   = problem.NewType("logarithm_domain", http.StatusBadRequest, "Logarithm is not defined for these operands")
+	problemTangentUndefined  = problem.NewType("tangent_undefined", http.StatusBadRequest, "Tangent is not defined for this angle")
+	problemInverseTrigDomain = problem.NewType("inverse_trig_domain", http.StatusBadRequest, "Operand is outside of [-1, 1]")
+	problemOutOfRange        = problem.NewType("result_out_of_range", http.StatusBadRequest, "Result is out of range")
+	problemInvalidUnit       = problem.NewType("invalid_unit", http.StatusBadRequest, "Unknown angle unit")
+	problemInvalidPrecision  = problem.NewType("invalid_precision", http.StatusBadRequest, "Precision is out of range")
+	problemPreciseNotAllowed = problem.NewType("precision_not_supported", http.StatusBadRequest, "Operation has no precise mode")
+
+	problemInvalidQuery     = problem.NewType("invalid_query", http.StatusBadRequest, "History query is invalid")
+	problemInvalidCursor    = problem.NewType("invalid_cursor", http.StatusBadRequest, "Cursor is invalid")
+	problemInvalidBatch     = problem.NewType("invalid_batch", http.StatusBadRequest, "Batch is invalid")
+	problemUnknownOperation = problem.NewType("unknown_operation", http.StatusBadRequest, "Unknown operation")
+	problemStorage          = problem.NewType("storage_error", http.StatusInternalServerError, "Storage failed")
+	problemQueryUnsupported = problem.NewType("history_query_not_supported", http.StatusNotImplemented, "Storage backend can't query the history")
+	problemUpgrade          = problem.NewType("websocket_upgrade_failed", http.StatusBadRequest, "WebSocket handshake failed")
+
+	problemSessionNotFound  = problem.NewType("session_not_found", http.StatusNotFound, "Session not found or expired")
+	problemTooManySessions  = problem.NewType("too_many_sessions", http.StatusTooManyRequests, "Too many sessions for this client")
+	problemSessionLimit     = problem.NewType("session_limit_reached", http.StatusServiceUnavailable, "Server has too many open sessions")
+	problemNoAns            = problem.NewType("no_previous_result", http.StatusBadRequest, "There is no previous result yet")
+	problemMemoryAction     = problem.NewType("invalid_memory_action", http.StatusBadRequest, "Unknown memory action")
+	problemFunctionExists   = problem.NewType("function_exists", http.StatusConflict, "Function already exists")
+	problemFunctionCycle    = problem.NewType("function_cycle", http.StatusUnprocessableEntity, "Function definition is recursive")
+	problemFunctionNotFound = problem.NewType("function_not_found", http.StatusNotFound, "Function not found")
+	problemTooManyFunctions = problem.NewType("too_many_functions", http.StatusBadRequest, "Too many functions")
+	problemInvalidTenant    = problem.NewType("invalid_tenant", http.StatusBadRequest, "Tenant ID is invalid")
+)
+
+// domainError carries the problem type of an operation error, its message is what clients always got
+type domainError struct {
+	kind    problem.Type
+	message string
+}
+
+func newDomainError(kind problem.Type, message string) error {
+	return &domainError{kind: kind, message: message}
+}
+
+func (e *domainError) Error() string {
+	return e.message
+}
+
+// problemFor maps err to a problem: expression and domain errors have their own types, anything else gets fallback
+func problemFor(err error, fallback problem.Type) *problem.Problem {
+	var syntaxErr *expression.SyntaxError
+	var evalErr *expression.EvalError
+	var domainErr *domainError
+	switch {
+	case errors.As(err, &syntaxErr):
+		p := problemInvalidExpression.New(syntaxErr.Message)
+		p.Position = &syntaxErr.Position
+		return p
+	case errors.As(err, &evalErr):
+		kind, ok := problem.Lookup(evalErr.Code)
+		if !ok {
+			kind = problemEvaluation
+		}
+		p := kind.New(evalErr.Message)
+		p.Position = &evalErr.Position
+		return p
+	case errors.As(err, &domainErr):
+		return domainErr.kind.New(domainErr.message)
+	default:
+		return fallback.New(err.Error())
+	}
+}
+
+// respondError replies with the problem matching err, see problemFor
+func respondError(c *gin.Context, err error, fallback problem.Type) {
+	problem.Write(c, problemFor(err, fallback))
+}
+
+// respondBindError replies to a request that could not be bound, operands that are not numbers keep their own type
+func respondBindError(c *gin.Context, err error) {
+	var domainErr *domainError
+	if errors.As(err, &domainErr) {
+		problem.Write(c, domainErr.kind.New(domainErr.message))
+		return
+	}
+	problem.Write(c, problem.FromBindError(err))
+}
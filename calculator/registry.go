@@ -0,0 +1,213 @@
+package calculator
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+
+	"CalculatorWebService/calculator/precise"
+)
+
+// Notation defines how an operation is rendered into the stored expression.
+type Notation string
+
+const (
+	Infix  Notation = "infix"  // 2 ^ 10 = 1024
+	Prefix Notation = "prefix" // log(8, 2) = 3
+)
+
+// Operation describes a single calculator operation. Registering one is enough to get
+// a POST /calculate/<Path> route and an entry in GET /calculate/operations.
+type Operation struct {
+	Name        string   // reported as "operation" in responses
+	Path        string   // route segment under /calculate/
+	Symbol      string   // operator or function name used in the expression
+	Notation    Notation // infix is only meaningful for binary operations
+	Arity       int      // 1 or 2
+	Angular     bool     // operand or result is an angle, unit is taken from the request
+	Constraints []string // human readable domain constraints for clients building their UI
+
+	// Validate performs domain checks before evaluation, nil means any finite operands are fine.
+	Validate func(operands []float64, unit AngleUnit) error
+	// Evaluate must only be called with operands that passed Validate.
+	Evaluate func(operands []float64, unit AngleUnit) float64
+	// Precise is the optional arbitrary-precision implementation, binary operations only.
+	Precise func(a, b *big.Rat) (*big.Rat, error)
+}
+
+// Registry holds operations in registration order, so the discovery endpoint output is stable.
+type Registry struct {
+	operations []Operation
+	byName     map[string]int
+	mu         sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		operations: make([]Operation, 0),
+		byName:     make(map[string]int),
+	}
+}
+
+// NewDefaultRegistry returns a registry filled with the built-in operations.
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, op := range builtinOperations() {
+		if err := r.Register(op); err != nil {
+			// built-in catalogue is static, failing here is a programming error
+			panic(err)
+		}
+	}
+	return r
+}
+
+func (r *Registry) Register(op Operation) error {
+	if op.Name == "" || op.Path == "" || op.Symbol == "" {
+		return errors.New("operation name, path and symbol are required")
+	}
+	if op.Arity != 1 && op.Arity != 2 {
+		return errors.New("operation " + op.Name + " must have arity 1 or 2")
+	}
+	if op.Evaluate == nil {
+		return errors.New("operation " + op.Name + " has no evaluator")
+	}
+	if op.Notation == "" {
+		op.Notation = Prefix
+	}
+	if op.Notation == Infix && op.Arity != 2 {
+		return errors.New("operation " + op.Name + ": infix notation requires two operands")
+	}
+	if op.Precise != nil && op.Arity != 2 {
+		return errors.New("operation " + op.Name + ": precise mode is only supported for binary operations")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.operations {
+		if existing.Name == op.Name || existing.Path == op.Path {
+			return errors.New("operation " + op.Name + " is already registered")
+		}
+	}
+	r.byName[op.Name] = len(r.operations)
+	r.operations = append(r.operations, op)
+	return nil
+}
+
+func (r *Registry) Get(name string) (Operation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i, ok := r.byName[name]
+	if !ok {
+		return Operation{}, false
+	}
+	return r.operations[i], true
+}
+
+func (r *Registry) List() []Operation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]Operation, len(r.operations))
+	copy(list, r.operations)
+	return list
+}
+
+// apply validates and evaluates the operation in float mode, or in precise mode when digits > 0.
+func (op Operation) apply(operands []Number, unitName string, digits int) (Response, error) {
+	if len(operands) != op.Arity {
+		return Response{}, errors.New(op.Name + " expects " + pluralOperands(op.Arity))
+	}
+	if digits > 0 {
+		return op.applyPrecise(operands, digits)
+	}
+
+	unit, err := parseAngleUnit(unitName)
+	if err != nil {
+		return Response{}, err
+	}
+
+	values := make([]float64, len(operands))
+	for i, operand := range operands {
+		if values[i], err = operand.Float64(); err != nil {
+			return Response{}, err
+		}
+	}
+	if op.Validate != nil {
+		if err := op.Validate(values, unit); err != nil {
+			return Response{}, err
+		}
+	}
+	result, err := finite(op.Evaluate(values, unit))
+	if err != nil {
+		return Response{}, err
+	}
+
+	formatted := make([]string, len(values))
+	for i, v := range values {
+		formatted[i] = formatFloat(v)
+	}
+	response := Response{
+		Result:     result,
+		Operation:  op.Name,
+		Expression: op.format(formatted, formatFloat(result)),
+		operands:   formatted,
+	}
+	if op.Angular {
+		response.Unit = string(unit)
+	}
+	return response, nil
+}
+
+// applyPrecise works on exact rationals and rounds only the final result to the requested significant digits.
+func (op Operation) applyPrecise(operands []Number, digits int) (Response, error) {
+	a, err := operands[0].Rat()
+	if err != nil {
+		return Response{}, err
+	}
+	b, err := operands[1].Rat()
+	if err != nil {
+		return Response{}, err
+	}
+
+	result, err := op.Precise(a, b)
+	if errors.Is(err, precise.ErrDivisionByZero) {
+		// keep the client facing message identical to the float mode
+		return Response{}, errDivisionByZero
+	}
+	if err != nil {
+		return Response{}, err
+	}
+
+	// result is the nearest float64, inexact for most decimals which is what exact_result is for.
+	// Beyond the float64 range there is no nearest value and the response couldn't be encoded.
+	approx, accurate := result.Float64()
+	if !accurate && math.IsInf(approx, 0) {
+		return Response{}, errOutOfRange
+	}
+	exact := precise.Format(result, digits)
+
+	formatted := []string{operands[0].String(), operands[1].String()}
+	return Response{
+		Result:      approx,
+		ExactResult: exact,
+		Precision:   digits,
+		Operation:   op.Name,
+		Expression:  op.format(formatted, exact),
+		operands:    formatted,
+	}, nil
+}
+
+func (op Operation) format(operands []string, result string) string {
+	if op.Notation == Infix {
+		return operands[0] + " " + op.Symbol + " " + operands[1] + " = " + result
+	}
+	return op.Symbol + "(" + strings.Join(operands, ", ") + ") = " + result
+}
+
+func pluralOperands(n int) string {
+	if n == 1 {
+		return "1 operand"
+	}
+	return "2 operands"
+}
@@ -2,16 +2,35 @@ package calculator
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
+	calculatorv1 "CalculatorWebService/api/calculator/v1"
+	"CalculatorWebService/calculator/feed"
+	"CalculatorWebService/calculator/functions"
+	"CalculatorWebService/calculator/session"
 	"CalculatorWebService/calculator/storage"
 	"CalculatorWebService/internal/config"
+	"CalculatorWebService/internal/health"
 	"CalculatorWebService/internal/logger"
 	"CalculatorWebService/internal/metrics"
+	"CalculatorWebService/internal/negotiate"
+	"CalculatorWebService/internal/openapi"
+	"CalculatorWebService/internal/problem"
+	"CalculatorWebService/internal/ratelimit"
+	"CalculatorWebService/internal/requestid"
+	"CalculatorWebService/internal/tracing"
 )
 
 // Service struct represents the calculator service with its router, handler, metrics, and HTTP server.
@@ -23,9 +42,23 @@ type Service struct {
 	metrics *metrics.Metrics
 	server  *http.Server
 	config  config.CalculatorConfig
+	limiter *ratelimit.Limiter // nil without a rate limit
+
+	// gRPC API on its own port, served by the same process with the same handler
+	grpc       *grpc.Server
+	grpcHealth *grpchealth.Server
+	api        *openapi.Document
+
+	// probes, see health.go
+	health  *health.Registry
+	started time.Time
+	build   health.BuildInfo
 }
 
-func NewService(configs config.Configs) *Service {
+// storageSizeTimeout bounds the storage size queries of a metrics scrape
+const storageSizeTimeout = 2 * time.Second
+
+func NewService(configs config.Configs) (*Service, error) {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 
@@ -36,14 +69,108 @@ func NewService(configs config.Configs) *Service {
 	metricsConfig.ServiceName = "calculator" // in a real scenario, this might come from a constant + instance identifier
 	metricsConfig.ServiceVersion = serviceConfig.Version
 
+	tracingConfig := configs[config.TracingConfigKey].(config.TracingConfig)
+	tracingConfig.ServiceName = metricsConfig.ServiceName
+	tracingConfig.ServiceVersion = serviceConfig.Version
+	if err := tracing.Init(tracingConfig); err != nil {
+		return nil, fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+
 	newMetrics := metrics.NewMetrics(metricsConfig)
+	router.Use(requestid.Middleware())
+	router.Use(tracing.Middleware())
 	router.Use(logger.LoggingMiddleware())
 	router.Use(newMetrics.PrometheusMiddleware())
-	router.Use(gin.Recovery())
-	newStorage, err := storage.NewStorage(serviceConfig.StorageType, serviceConfig.StorageFilePath)
+	router.Use(problem.Recovery())
+	router.HandleMethodNotAllowed = true
+	// ClientIP keys the session caps and the history, only the proxies we run may vouch for it
+	if err := router.SetTrustedProxies(serviceConfig.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("failed to set trusted proxies: %w", err)
+	}
+	router.NoRoute(problem.NoRoute)
+	router.NoMethod(problem.NoMethod)
+	newStorage, err := storage.NewStorage(storage.Options{
+		Type:         serviceConfig.StorageType,
+		FilePath:     serviceConfig.StorageFilePath,
+		SyncPolicy:   storage.SyncPolicy(serviceConfig.StorageSync),
+		SyncInterval: serviceConfig.StorageSyncInterval,
+	})
 	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	if reporter, ok := newStorage.(storage.FlushReporter); ok {
+		newMetrics.GaugeFunc("storage_unflushed_records", func() float64 {
+			return float64(reporter.Unflushed())
+		})
+	}
+	if reporter, ok := newStorage.(storage.SizeReporter); ok {
+		// scraped separately, each gauge asks the backend once per scrape
+		storageSize := func() storage.Size {
+			ctx, cancel := context.WithTimeout(context.Background(), storageSizeTimeout)
+			defer cancel()
+			size, err := reporter.Size(ctx)
+			if err != nil {
+				logger.LogError("Failed to read storage size", err, logrus.Fields{"storage": serviceConfig.StorageType})
+			}
+			return size
+		}
+		newMetrics.GaugeFunc("storage_calculations", func() float64 {
+			return float64(storageSize().Calculations)
+		})
+		newMetrics.GaugeFunc("storage_size_bytes", func() float64 {
+			return float64(storageSize().Bytes)
+		})
+	}
+	hub := feed.NewHub(serviceConfig.StreamBuffer)
+	hub.OnDrop = func() {
+		newMetrics.CountInc("feed_dropped_events_total", prometheus.Labels{})
 	}
-	handler := NewCalculationHandler(newStorage)
+	newMetrics.GaugeFunc("feed_subscribers", func() float64 {
+		return float64(hub.Subscribers())
+	})
+	functionLimits := functions.Limits{
+		MaxFunctions: serviceConfig.FunctionMax,
+		MaxDepth:     serviceConfig.FunctionMaxDepth,
+		MaxSteps:     serviceConfig.FunctionMaxSteps,
+	}
+	sessions := session.NewManager(serviceConfig.SessionTTL, serviceConfig.SessionMax, serviceConfig.SessionMaxPerClient, serviceConfig.SessionHistory, functionLimits)
+	newMetrics.GaugeFunc("sessions_active", func() float64 {
+		return float64(sessions.Count())
+	})
+	functionStore, ok := newStorage.(storage.FunctionStore)
+	if !ok {
+		logger.LogWarn("Storage can't persist functions, they are kept in memory only", logrus.Fields{"storage": serviceConfig.StorageType})
+	}
+	library := functions.NewLibrary(storage.TracedFunctions(functionStore, serviceConfig.StorageType), functionLimits)
+	// every successful Store reaches the live feed, whichever API it came from
+	traced := storage.Traced(newStorage, serviceConfig.StorageType)
+	handler := NewCalculationHandler(feed.Publishing(traced, hub), NewDefaultRegistry(), serviceConfig.Precision, hub, sessions, library)
+	handler.BatchWorkers = serviceConfig.BatchWorkers
+	handler.BatchMaxItems = serviceConfig.BatchMaxItems
+	handler.Metrics = newMetrics
+	newMetrics.SetBuckets("calculation_duration_seconds", metrics.LatencyBuckets)
+
+	var limiter *ratelimit.Limiter
+	if serviceConfig.RateLimit > 0 {
+		limiter = ratelimit.New(serviceConfig.RateLimit, serviceConfig.RateBurst)
+	}
+
+	grpcHealth := grpchealth.NewServer()
+	// the request ID comes first so every other interceptor sees it, refused calls are logged and counted,
+	// recovery is the innermost interceptor, so panics are logged and counted as Internal
+	unary := []grpc.UnaryServerInterceptor{requestid.UnaryServerInterceptor(), logger.UnaryServerInterceptor(), newMetrics.UnaryServerInterceptor()}
+	if limiter != nil {
+		unary = append(unary, rateLimitUnaryInterceptor(limiter))
+	}
+	rpcServer := grpc.NewServer(
+		grpc.StatsHandler(tracing.ServerHandler()),
+		grpc.ChainUnaryInterceptor(append(unary, recoveryUnaryInterceptor)...),
+		grpc.ChainStreamInterceptor(requestid.StreamServerInterceptor(), logger.StreamServerInterceptor(), newMetrics.StreamServerInterceptor(), recoveryStreamInterceptor),
+	)
+	calculatorv1.RegisterCalculatorServer(rpcServer, &grpcServer{handler: handler})
+	healthpb.RegisterHealthServer(rpcServer, grpcHealth)
+	reflection.Register(rpcServer)
+	grpcHealth.SetServingStatus(calculatorv1.Calculator_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
 
 	server := &Service{
 		router:  router,
@@ -56,65 +183,175 @@ func NewService(configs config.Configs) *Service {
 			WriteTimeout: serviceConfig.WriteTimeout,
 			IdleTimeout:  serviceConfig.IdleTimeout,
 		},
-		config: serviceConfig,
+		config:     serviceConfig,
+		limiter:    limiter,
+		grpc:       rpcServer,
+		grpcHealth: grpcHealth,
+		api:        newAPIDocument(apiRoutes(handler.Operations), serviceConfig.Version),
+		health:     health.NewRegistry(serviceConfig.HealthCacheTTL, serviceConfig.HealthTimeout),
+		started:    time.Now().UTC(),
+		build:      health.Build(serviceConfig.Version),
 	}
+	registerChecks(server.health, newStorage, serviceConfig)
 	server.setupRoutes()
-	return server
+	return server, nil
 }
 
+// Start serves HTTP and gRPC until Shutdown, it returns nil once Shutdown stopped the servers
 func (s *Service) Start() error {
+	// listen before serving HTTP, so a busy gRPC port fails the start instead of being logged later
+	listener, err := net.Listen("tcp", ":"+s.config.GRPCPort)
+	if err != nil {
+		return fmt.Errorf("gRPC listen: %w", err)
+	}
+	go func() {
+		if err := s.grpc.Serve(listener); err != nil {
+			logger.LogError("gRPC server error", err)
+		}
+	}()
+
 	logger.LogInfo("Calculator starting", logrus.Fields{
-		"address": s.server.Addr,
+		"address":      s.server.Addr,
+		"grpc_address": listener.Addr().String(),
 	})
-	return s.server.ListenAndServe()
+	if err := s.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
+// Shutdown stops the service in phases, each one logged with its duration:
+//
+//  1. not ready: the readiness probes fail, after ShutdownDelay the load balancers have noticed
+//  2. drain: the listeners close and in-flight requests and calls finish, until ctx is done
+//  3. storage: everything acknowledged during the drain is flushed, then the storage is closed
+//  4. telemetry: buffered spans are exported
+//
+// Storage is closed only once nothing can Store anymore, so a calculation answered with 200 is never lost.
+// When ctx ends before the drain is over, the remaining connections are cut and the later phases still run.
 func (s *Service) Shutdown(ctx context.Context) {
 	logger.LogInfo("Shutting down calculator...")
+	start := time.Now()
 
-	logger.LogInfo("Saving records in file...")
-	err := s.handler.Storage.Close()
-	if err != nil {
-		logger.LogError("Error closing storage", err)
-	} else {
-		logger.LogInfo("Records saved successfully")
-	}
+	shutdownPhase("not_ready", func() error {
+		s.health.ShutDown()
+		s.grpcHealth.Shutdown() // reports NOT_SERVING to gRPC health checks
+		select {
+		case <-time.After(s.config.ShutdownDelay):
+		case <-ctx.Done():
+		}
+		return nil
+	})
+
+	shutdownPhase("drain", func() error {
+		// the live streams only end with the hub, they would hold the drain until the deadline
+		s.handler.Feed.Close()
+		grpcStopped := make(chan struct{})
+		go func() {
+			s.grpc.GracefulStop()
+			close(grpcStopped)
+		}()
 
-	if s.server != nil {
 		err := s.server.Shutdown(ctx)
 		if err != nil {
-			logger.LogError("Calculator forced to shutdown", err)
-		} else {
-			logger.LogInfo("Calculator shutdown complete")
+			err = errors.Join(err, s.server.Close())
 		}
+		select {
+		case <-grpcStopped:
+		case <-ctx.Done():
+			s.grpc.Stop()
+			err = errors.Join(err, fmt.Errorf("gRPC forced to stop: %w", ctx.Err()))
+		}
+		return err
+	})
+
+	shutdownPhase("storage", func() error {
+		s.handler.Sessions.Close()
+		return s.handler.Storage.Close()
+	})
+
+	shutdownPhase("telemetry", func() error {
+		// the deadline may be over already, the spans of the drained requests still deserve a try
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), telemetryFlushTimeout)
+		defer cancel()
+		return tracing.Shutdown(flushCtx)
+	})
+
+	logger.LogInfo("Calculator shutdown complete", logrus.Fields{"duration": time.Since(start)})
+}
+
+// telemetryFlushTimeout bounds the export of the last spans
+const telemetryFlushTimeout = 5 * time.Second
+
+// shutdownPhase runs a shutdown phase and logs how long it took, a failed phase doesn't stop the next ones
+func shutdownPhase(name string, phase func() error) {
+	start := time.Now()
+	err := phase()
+	fields := logrus.Fields{"phase": name, "duration": time.Since(start)}
+	if err != nil {
+		logger.LogError("Shutdown phase failed", err, fields)
+		return
 	}
-	logger.LogInfo("Calculator shutdown complete")
+	logger.LogInfo("Shutdown phase completed", fields)
 }
 
 func (s *Service) setupRoutes() {
-	s.router.POST("/calculate/addition", s.handler.Addition)
-	s.router.POST("/calculate/subtraction", s.handler.Subtraction)
-	s.router.POST("/calculate/multiplication", s.handler.Multiplication)
-	s.router.POST("/calculate/division", s.handler.Division)
-	s.router.GET("/calculate/recent", s.handler.GetRecentCalculations)
+	// the API answers in the format of the Accept header, the live feeds have their own protocols.
+	// The Accept header is checked against the response type of the route before the handler runs,
+	// so a request that can't be answered has no side effects. Clients over the rate limit are refused first.
+	responses := make(map[string]interface{})
+	for _, route := range apiRoutes(s.handler.Operations) {
+		responses[route.Method+" "+route.Path] = route.Response
+	}
+	var middleware []gin.HandlerFunc
+	if s.limiter != nil {
+		middleware = append(middleware, s.limiter.Middleware())
+	}
+	middleware = append(middleware, negotiate.Middleware(func(c *gin.Context) interface{} {
+		return responses[c.Request.Method+" "+c.FullPath()]
+	}), negotiate.LimitBody(int64(s.config.MaxBodyBytes)))
+	api := s.router.Group("", middleware...)
+	for _, op := range s.handler.Operations.List() {
+		api.POST("/calculate/"+op.Path, s.handler.Calculate(op))
+	}
+	api.POST("/calculate/expression", s.handler.Expression)
+	api.POST("/calculate/batch", s.handler.Batch)
+	api.GET("/calculate/operations", s.handler.ListOperations)
+	api.GET("/calculate/recent", s.handler.GetRecentCalculations)
+	api.GET("/calculate/recent/:n", s.handler.GetRecentCalculations)
+	api.GET("/calculate/history", s.handler.GetHistory)
+	s.router.GET("/calculate/stream", s.handler.Stream)
+	s.router.GET("/calculate/ws", s.handler.StreamWebSocket)
+
+	sessions := api.Group("/sessions")
+	sessions.POST("", s.handler.CreateSession)
+	sessions.GET("/:id", s.handler.GetSession)
+	sessions.DELETE("/:id", s.handler.DeleteSession)
+	sessions.POST("/:id/evaluate", s.handler.SessionEvaluate)
+	sessions.POST("/:id/memory", s.handler.SessionMemory)
+	sessions.GET("/:id/history", s.handler.SessionHistory)
+	sessions.POST("/:id/functions", s.handler.DefineSessionFunction)
+	sessions.GET("/:id/functions", s.handler.ListSessionFunctions)
+	sessions.DELETE("/:id/functions/:name", s.handler.DeleteSessionFunction)
+
+	// tenant functions, the tenant comes from the X-Tenant-ID header
+	userFunctions := api.Group("/functions")
+	userFunctions.POST("", s.handler.DefineFunction)
+	userFunctions.GET("", s.handler.ListFunctions)
+	userFunctions.DELETE("/:name", s.handler.DeleteFunction)
+	userFunctions.POST("/evaluate", s.handler.EvaluateFunctions)
 
 	s.router.GET("/metrics", gin.WrapH(*s.metrics.Handler))
-	s.router.GET("/health", s.HealthCheck)
+	s.router.GET("/livez", s.Livez)
+	s.router.GET("/readyz", s.Readyz)
+	s.router.GET("/health", s.Livez) // kept for clients of the old health check
+	s.router.GET("/problems", problem.ListTypes)
+	s.router.GET("/problems/:code", problem.GetType)
+	s.router.GET(openAPIPath, s.OpenAPI)
+	s.router.GET(docsPath, gin.WrapF(openapi.UIHandler("Calculator API", openAPIPath)))
 }
 
-func (s *Service) HealthCheck(c *gin.Context) {
-	response := struct {
-		Status    string    `json:"status"`
-		Timestamp time.Time `json:"timestamp"`
-		Service   string    `json:"service"`
-		Version   string    `json:"version"`
-		Uptime    string    `json:"uptime"`
-	}{
-		Status:    "healthy",
-		Timestamp: time.Now().UTC(),
-		Service:   "calculator",
-		Version:   s.config.Version,
-	}
-
-	c.JSON(http.StatusOK, response)
+// OpenAPI serves the API description, it's built once on startup
+func (s *Service) OpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, s.api)
 }
@@ -0,0 +1,282 @@
+package calculator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"CalculatorWebService/calculator/storage"
+	"CalculatorWebService/internal/config"
+	"CalculatorWebService/internal/logger"
+	"CalculatorWebService/internal/problem"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitLogger(config.LoggerConfig{Level: "error", Format: "text", TimeFormat: time.RFC3339})
+	os.Exit(m.Run())
+}
+
+// newTestService builds the service from the default configuration with flags on top, like the command line.
+// It runs on the memory storage unless a flag says otherwise, the HTTP server is not started.
+func newTestService(t *testing.T, flags ...string) *Service {
+	t.Helper()
+	args := append([]string{"--calculator.storage_type=memory", "--tracing.exporter=none"}, flags...)
+	loader, err := config.NewLoader(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs := loader.LoadConfigs([]string{config.MetricsConfigKey, config.CalculatorConfigKey, config.TracingConfigKey})
+	if err := loader.Validate(configs, ValidateConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewService(configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// Every calculation answered with 200 must be in the storage once Shutdown returned, including
+// the ones still in flight when it started: the storage is closed only after the drain.
+func TestShutdownKeepsEveryAcceptedCalculation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.txt")
+	s := newTestService(t, "--calculator.storage_type=file", "--calculator.storage_path="+path)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.server.Serve(listener) }()
+	url := "http://" + listener.Addr().String() + "/calculate/addition"
+
+	const workers, acceptedBeforeShutdown = 16, 200
+	var (
+		mu       sync.Mutex
+		accepted = make(map[string]bool) // request IDs answered with 200
+		busy     = make(chan struct{})   // closed once the load is running
+		wg       sync.WaitGroup
+	)
+	client := &http.Client{Timeout: 10 * time.Second}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				requestID := fmt.Sprintf("worker-%d-%d", w, i)
+				req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(fmt.Sprintf(`{"operand1": %d, "operand2": %d}`, w, i)))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("X-Request-ID", requestID)
+				resp, err := client.Do(req)
+				if err != nil {
+					return // the listener is closed, the service is shutting down
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					continue
+				}
+				mu.Lock()
+				accepted[requestID] = true
+				if len(accepted) == acceptedBeforeShutdown {
+					close(busy)
+				}
+				mu.Unlock()
+			}
+		}(w)
+	}
+
+	select {
+	case <-busy:
+	case <-time.After(10 * time.Second):
+		t.Fatal("load didn't get going")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s.Shutdown(ctx)
+	wg.Wait()
+	if err := <-served; err != http.ErrServerClosed {
+		t.Errorf("Serve returned %v, want %v", err, http.ErrServerClosed)
+	}
+
+	reopened, err := storage.NewFileStorage(path, storage.SyncNone, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	stored, err := reopened.GetRecent(context.Background(), len(accepted)*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool, len(stored))
+	for _, calc := range stored {
+		found[calc.RequestID] = true
+	}
+	lost := 0
+	for requestID := range accepted {
+		if !found[requestID] {
+			lost++
+			t.Errorf("calculation of %s was answered with 200 but is not in the storage", requestID)
+		}
+	}
+	t.Logf("%d calculations accepted, %d stored, %d lost", len(accepted), len(stored), lost)
+}
+
+// Regression: a body of a million parentheses crashed the process with a stack overflow
+func TestExpressionLimits(t *testing.T) {
+	s := newTestService(t, "--calculator.max_body_bytes=65536")
+	defer s.Shutdown(context.Background())
+
+	nested := func(levels int) string {
+		return fmt.Sprintf(`{"expression": "%s1%s"}`, strings.Repeat("(", levels), strings.Repeat(")", levels))
+	}
+	tests := []struct {
+		name     string
+		body     string
+		chunked  bool // no Content-Length, the limit is hit while reading
+		status   int
+		code     string
+		position int
+	}{
+		{name: "nested", body: nested(50), status: http.StatusOK},
+		{name: "nested too deep", body: nested(2000), status: http.StatusBadRequest, code: "invalid_expression", position: 100},
+		{name: "too long", body: `{"expression": "` + strings.Repeat("1+", 3000) + `1"}`, status: http.StatusBadRequest, code: "invalid_expression", position: 5000},
+		{name: "body too large", body: nested(1000000), status: http.StatusRequestEntityTooLarge, code: "payload_too_large"},
+		{name: "chunked body too large", body: nested(1000000), chunked: true, status: http.StatusRequestEntityTooLarge, code: "payload_too_large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				body = io.MultiReader(body) // hides the length from NewRequest
+			}
+			req := httptest.NewRequest(http.MethodPost, "/calculate/expression", body)
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.code == "" {
+				return
+			}
+			var p problem.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Code != tt.code || (p.Position != nil && *p.Position != tt.position) {
+				t.Errorf("problem = %s at %v, want %s at %d", p.Code, p.Position, tt.code, tt.position)
+			}
+		})
+	}
+}
+
+// Regression: sessions and functions were created before the response failed with 406
+func TestNotAcceptableHasNoSideEffects(t *testing.T) {
+	s := newTestService(t)
+	defer s.Shutdown(context.Background())
+
+	for _, path := range []string{"/sessions", "/functions"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"definition": "f(x) = x"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/csv")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotAcceptable {
+			t.Errorf("POST %s: status = %d, want %d", path, w.Code, http.StatusNotAcceptable)
+		}
+	}
+	if n := s.handler.Sessions.Count(); n != 0 {
+		t.Errorf("%d sessions created", n)
+	}
+	set, err := s.handler.Functions.Tenant(context.Background(), defaultTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defs := set.List(); len(defs) != 0 {
+		t.Errorf("functions defined: %v", defs)
+	}
+}
+
+// Regression: the per-client session cap trusted X-Forwarded-For from any peer
+func TestSessionCapsKeyOnTheTrustedClientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		flags  []string
+		remote string // address of the second client
+		want   int    // status of its session
+	}{
+		{"forwarded for from anyone", nil, "192.0.2.1:1234", http.StatusTooManyRequests},
+		{"forwarded for from a trusted proxy", []string{"--calculator.trusted_proxies=192.0.2.0/24"}, "192.0.2.1:1234", http.StatusCreated},
+		{"total cap", []string{"--calculator.session_max=1"}, "198.51.100.1:1234", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, append([]string{"--calculator.session_max_per_client=1"}, tt.flags...)...)
+			defer s.Shutdown(context.Background())
+
+			create := func(remote, forwardedFor string) int {
+				req := httptest.NewRequest(http.MethodPost, "/sessions", nil)
+				req.RemoteAddr = remote
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+				w := httptest.NewRecorder()
+				s.router.ServeHTTP(w, req)
+				return w.Code
+			}
+			if code := create("192.0.2.1:1234", "203.0.113.1"); code != http.StatusCreated {
+				t.Fatalf("first session: status = %d, want %d", code, http.StatusCreated)
+			}
+			if code := create(tt.remote, "203.0.113.2"); code != tt.want {
+				t.Errorf("second session: status = %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s := newTestService(t, "--calculator.rate_limit=0.001", "--calculator.rate_burst=2")
+	defer s.Shutdown(context.Background())
+
+	serve := func(path, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		if w := serve("/calculate/operations", "192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i, w.Code, http.StatusOK)
+		}
+	}
+	w := serve("/calculate/operations", "192.0.2.1:1234")
+	var p problem.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusTooManyRequests || p.Code != problem.RateLimited.Code || w.Header().Get("Retry-After") == "" {
+		t.Errorf("over the limit: status = %d, problem %s, Retry-After %q", w.Code, p.Code, w.Header().Get("Retry-After"))
+	}
+	if w := serve("/calculate/operations", "198.51.100.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("another client: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := serve("/livez", "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("probes are not limited: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,182 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"CalculatorWebService/calculator/functions"
+)
+
+var (
+	ErrNotFound        = errors.New("session not found or expired")
+	ErrTooManySessions = errors.New("too many sessions for this client")
+	ErrSessionLimit    = errors.New("the server has too many open sessions, try again later")
+)
+
+// Manager owns all sessions. A session expires after ttl without being used,
+// expired sessions are unreachable right away and swept in the background.
+type Manager struct {
+	sessions     map[string]*Session
+	perClient    map[string]int
+	ttl          time.Duration
+	maxSessions  int
+	maxPerClient int
+	maxHistory   int
+	limits       functions.Limits
+	mu           sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewManager caps the open sessions at maxSessions in total and maxPerClient per client, 0 leaves a cap out
+func NewManager(ttl time.Duration, maxSessions, maxPerClient, maxHistory int, limits functions.Limits) *Manager {
+	if maxHistory < 1 {
+		maxHistory = 1
+	}
+	m := &Manager{
+		sessions:     make(map[string]*Session),
+		perClient:    make(map[string]int),
+		ttl:          ttl,
+		maxSessions:  maxSessions,
+		maxPerClient: maxPerClient,
+		maxHistory:   maxHistory,
+		limits:       limits,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go m.sweepLoop()
+	return m
+}
+
+// Create starts a new session for the client, ErrTooManySessions when the client already has the maximum
+// and ErrSessionLimit when all clients together do
+func (m *Manager) Create(clientIP string) (*Session, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	s := &Session{
+		ID:         id,
+		ClientIP:   clientIP,
+		CreatedAt:  now,
+		lastUsed:   now,
+		variables:  make(map[string]float64),
+		functions:  functions.NewSet(m.limits),
+		history:    make([]Entry, 0),
+		maxHistory: m.maxHistory,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now) // expired sessions must not count against the caps
+	if m.maxSessions > 0 && len(m.sessions) >= m.maxSessions {
+		return nil, ErrSessionLimit
+	}
+	if m.maxPerClient > 0 && m.perClient[clientIP] >= m.maxPerClient {
+		return nil, ErrTooManySessions
+	}
+	m.sessions[id] = s
+	m.perClient[clientIP]++
+	return s, nil
+}
+
+// Get returns a live session and extends its lifetime
+func (m *Manager) Get(id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	now := time.Now().UTC()
+	if !ok || m.expired(s, now) {
+		return nil, ErrNotFound
+	}
+	s.lastUsed = now
+	return s, nil
+}
+
+// State returns a snapshot of the session, including when it expires
+func (m *Manager) State(s *Session) State {
+	m.mu.Lock()
+	expiresAt := s.lastUsed.Add(m.ttl)
+	m.mu.Unlock()
+	return s.state(expiresAt)
+}
+
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok || m.expired(s, time.Now().UTC()) {
+		return ErrNotFound
+	}
+	m.remove(s)
+	return nil
+}
+
+// Count returns the number of sessions, expired ones that are not swept yet included
+func (m *Manager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+// Close stops the background sweeping
+func (m *Manager) Close() {
+	close(m.stop)
+	<-m.done
+}
+
+func (m *Manager) sweepLoop() {
+	defer close(m.done)
+	interval := m.ttl / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.mu.Lock()
+			m.sweep(time.Now().UTC())
+			m.mu.Unlock()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// sweep must be called with the lock held
+func (m *Manager) sweep(now time.Time) {
+	for _, s := range m.sessions {
+		if m.expired(s, now) {
+			m.remove(s)
+		}
+	}
+}
+
+func (m *Manager) expired(s *Session, now time.Time) bool {
+	return now.Sub(s.lastUsed) > m.ttl
+}
+
+// remove must be called with the lock held
+func (m *Manager) remove(s *Session) {
+	delete(m.sessions, s.ID)
+	if m.perClient[s.ClientIP]--; m.perClient[s.ClientIP] <= 0 {
+		delete(m.perClient, s.ClientIP)
+	}
+}
+
+// newID returns 128 random bits, session IDs are the only thing protecting a session
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
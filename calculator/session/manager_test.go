@@ -0,0 +1,140 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"CalculatorWebService/calculator/functions"
+)
+
+func newTestManager(t *testing.T, ttl time.Duration, maxSessions, maxPerClient int) *Manager {
+	t.Helper()
+	m := NewManager(ttl, maxSessions, maxPerClient, 10, functions.Limits{MaxDepth: 8, MaxSteps: 1000})
+	t.Cleanup(m.Close)
+	return m
+}
+
+// age moves the last use of a session back in time, the way a client going quiet would
+func age(m *Manager, s *Session, by time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.lastUsed = s.lastUsed.Add(-by)
+}
+
+func TestManagerCaps(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxSessions  int
+		maxPerClient int
+		clients      []string
+		want         []error
+	}{
+		{"per client", 0, 2, []string{"a", "a", "a", "b"}, []error{nil, nil, ErrTooManySessions, nil}},
+		{"total", 3, 2, []string{"a", "b", "c", "d", "a"}, []error{nil, nil, nil, ErrSessionLimit, ErrSessionLimit}},
+		{"total before per client", 2, 1, []string{"a", "a", "b", "b"}, []error{nil, ErrTooManySessions, nil, ErrSessionLimit}},
+		{"no caps", 0, 0, []string{"a", "a", "a"}, []error{nil, nil, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, time.Hour, tt.maxSessions, tt.maxPerClient)
+			for i, client := range tt.clients {
+				s, err := m.Create(client)
+				if !errors.Is(err, tt.want[i]) {
+					t.Fatalf("session %d for %s: got %v, want %v", i, client, err, tt.want[i])
+				}
+				if err == nil && s.ClientIP != client {
+					t.Errorf("session %d belongs to %s, want %s", i, s.ClientIP, client)
+				}
+			}
+		})
+	}
+}
+
+func TestManagerDeleteFreesTheCaps(t *testing.T) {
+	m := newTestManager(t, time.Hour, 2, 1)
+	a, _ := m.Create("a")
+	if _, err := m.Create("b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create("c"); !errors.Is(err, ErrSessionLimit) {
+		t.Fatalf("got %v, want ErrSessionLimit", err)
+	}
+
+	if err := m.Delete(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete(a.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: got %v, want ErrNotFound", err)
+	}
+	if _, err := m.Create("a"); err != nil {
+		t.Errorf("client a after its session is deleted: %v", err)
+	}
+	if m.Count() != 2 || len(m.perClient) != 2 {
+		t.Errorf("%d sessions of %d clients, want 2 of 2", m.Count(), len(m.perClient))
+	}
+}
+
+func TestManagerExpiry(t *testing.T) {
+	m := newTestManager(t, time.Minute, 0, 1)
+	s, _ := m.Create("a")
+	kept, _ := m.Create("b")
+
+	age(m, s, 30*time.Second)
+	if _, err := m.Get(s.ID); err != nil {
+		t.Fatalf("session used within the TTL: %v", err)
+	}
+	// Get extended the lifetime, the session is good for another TTL
+	if expiresAt := m.State(s).ExpiresAt; time.Until(expiresAt) < 59*time.Second {
+		t.Errorf("expires at %v, want a minute from now", expiresAt)
+	}
+
+	age(m, s, 2*time.Minute)
+	if _, err := m.Get(s.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of an expired session: got %v, want ErrNotFound", err)
+	}
+	if err := m.Delete(s.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete of an expired session: got %v, want ErrNotFound", err)
+	}
+	if m.Count() != 2 {
+		t.Errorf("%d sessions before the sweep, want 2", m.Count())
+	}
+
+	m.mu.Lock()
+	m.sweep(time.Now().UTC())
+	m.mu.Unlock()
+	if m.Count() != 1 || m.perClient["a"] != 0 {
+		t.Errorf("%d sessions and %d of client a after the sweep, want 1 and 0", m.Count(), m.perClient["a"])
+	}
+	if _, err := m.Get(kept.ID); err != nil {
+		t.Errorf("live session was swept: %v", err)
+	}
+}
+
+func TestManagerCreateSweepsExpiredSessions(t *testing.T) {
+	m := newTestManager(t, time.Minute, 1, 1)
+	s, _ := m.Create("a")
+	if _, err := m.Create("b"); !errors.Is(err, ErrSessionLimit) {
+		t.Fatalf("got %v, want ErrSessionLimit", err)
+	}
+
+	age(m, s, 2*time.Minute)
+	if _, err := m.Create("a"); err != nil {
+		t.Errorf("expired session still counts against the caps: %v", err)
+	}
+}
+
+func TestManagerSweepLoop(t *testing.T) {
+	// the sweep runs every ttl/2 but not more often than once a second
+	m := newTestManager(t, time.Second, 0, 0)
+	s, _ := m.Create("a")
+	age(m, s, time.Hour)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for m.Count() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expired session was not swept in the background")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,225 @@
+// Package session keeps the state of interactive calculator sessions: variables, the last result,
+// the memory register and user-defined functions. Sessions live in memory only and expire after
+// a period of inactivity.
+package session
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"CalculatorWebService/calculator/expression"
+	"CalculatorWebService/calculator/functions"
+)
+
+// Reserved names can be read in expressions but not assigned
+const (
+	AnsVariable    = "ans" // result of the last evaluation
+	MemoryVariable = "MR"  // memory register
+)
+
+// Codes of the evaluation errors specific to sessions, see expression.EvalError
+const (
+	CodeReservedVariable = "reserved_variable"
+	CodeTooManyVariables = "too_many_variables"
+)
+
+// maxVariables bounds the memory a single session can take
+const maxVariables = 100
+
+var (
+	errTooManyVars    = errors.New("too many variables, the limit is " + strconv.Itoa(maxVariables))
+	ErrNoAns          = errors.New("there is no previous result yet")
+	ErrUnknownAction  = errors.New("memory action must be one of M+, M-, MR, MC")
+	ErrMemoryOverflow = errors.New("memory register would overflow, it is left unchanged")
+)
+
+// MemoryAction is one of the classic calculator memory keys
+type MemoryAction string
+
+const (
+	MemoryAdd      MemoryAction = "M+"
+	MemorySubtract MemoryAction = "M-"
+	MemoryRecall   MemoryAction = "MR"
+	MemoryClear    MemoryAction = "MC"
+)
+
+// Entry is a single evaluation in the session history
+type Entry struct {
+	ID         int       `json:"id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Expression string    `json:"expression"` // normalized, "x = 3.5" for assignments
+	Result     float64   `json:"result"`
+	Variable   string    `json:"variable,omitempty"` // set for assignments
+}
+
+// Result of Session.Evaluate
+type Result struct {
+	Result     float64 `json:"result"`
+	Expression string  `json:"expression"`
+	Variable   string  `json:"variable,omitempty"`
+}
+
+// State is a snapshot of the session for clients
+type State struct {
+	ID        string                 `json:"id"`
+	CreatedAt time.Time              `json:"created_at"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	Variables map[string]float64     `json:"variables"`
+	Ans       *float64               `json:"ans"` // null until the first evaluation
+	Memory    float64                `json:"memory"`
+	Functions []functions.Definition `json:"functions"`
+}
+
+type Session struct {
+	ID        string
+	ClientIP  string
+	CreatedAt time.Time
+
+	lastUsed time.Time // guarded by the manager lock
+
+	variables  map[string]float64
+	ans        float64
+	hasAns     bool
+	memory     float64
+	functions  *functions.Set
+	history    []Entry
+	maxHistory int
+	nextEntry  int
+	mu         sync.Mutex
+}
+
+// Lookup resolves variables for expression.EvaluateScope, must be called with the lock held.
+// ans is unknown until the first evaluation.
+func (s *Session) Lookup(name string) (float64, bool) {
+	switch name {
+	case AnsVariable:
+		return s.ans, s.hasAns
+	case MemoryVariable:
+		return s.memory, true
+	}
+	value, ok := s.variables[name]
+	return value, ok
+}
+
+// Evaluate runs an expression or an assignment ("x = ans * 2") in the session.
+// Every successful evaluation becomes the new ans.
+func (s *Session) Evaluate(src string) (Result, error) {
+	tree, err := expression.Parse(src)
+	if err != nil {
+		return Result{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	assign, isAssign := tree.(*expression.Assign)
+	if isAssign {
+		if assign.Name == AnsVariable || assign.Name == MemoryVariable {
+			return Result{}, &expression.EvalError{Position: assign.Pos, Code: CodeReservedVariable, Message: "'" + assign.Name + "' is reserved"}
+		}
+		if _, exists := s.variables[assign.Name]; !exists && len(s.variables) >= maxVariables {
+			return Result{}, &expression.EvalError{Position: assign.Pos, Code: CodeTooManyVariables, Message: errTooManyVars.Error()}
+		}
+	}
+
+	value, err := s.functions.Evaluate(tree, s)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Result: value}
+	if isAssign {
+		s.variables[assign.Name] = value
+		result.Variable = assign.Name
+		result.Expression = tree.String()
+	} else {
+		result.Expression = tree.String() + " = " + strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	s.ans, s.hasAns = value, true
+	s.record(result)
+	return result, nil
+}
+
+// Memory applies a memory key: M+ and M- add or subtract ans, MR recalls and MC clears the register.
+// It returns the register value afterwards.
+func (s *Session) Memory(action MemoryAction) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch action {
+	case MemoryAdd, MemorySubtract:
+		if !s.hasAns {
+			return 0, ErrNoAns
+		}
+		memory := s.memory + s.ans
+		if action == MemorySubtract {
+			memory = s.memory - s.ans
+		}
+		if math.IsInf(memory, 0) {
+			return s.memory, ErrMemoryOverflow
+		}
+		s.memory = memory
+	case MemoryRecall:
+		// like the key on a desk calculator, the recalled value becomes the current one
+		s.ans, s.hasAns = s.memory, true
+	case MemoryClear:
+		s.memory = 0
+	default:
+		return 0, ErrUnknownAction
+	}
+	return s.memory, nil
+}
+
+// Functions are the functions defined in this session, they are gone with it
+func (s *Session) Functions() *functions.Set {
+	return s.functions
+}
+
+// History returns the session history, oldest first
+func (s *Session) History() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history := make([]Entry, len(s.history))
+	copy(history, s.history)
+	return history
+}
+
+func (s *Session) state(expiresAt time.Time) State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := State{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt,
+		ExpiresAt: expiresAt,
+		Variables: make(map[string]float64, len(s.variables)),
+		Memory:    s.memory,
+		Functions: s.functions.List(),
+	}
+	for name, value := range s.variables {
+		state.Variables[name] = value
+	}
+	if s.hasAns {
+		ans := s.ans
+		state.Ans = &ans
+	}
+	return state
+}
+
+// record must be called with the lock held, the oldest entries go once the history is full
+func (s *Session) record(result Result) {
+	s.nextEntry++
+	entry := Entry{
+		ID:         s.nextEntry,
+		Timestamp:  time.Now().UTC(),
+		Expression: result.Expression,
+		Result:     result.Result,
+		Variable:   result.Variable,
+	}
+	if len(s.history) >= s.maxHistory {
+		s.history = append(s.history[:0], s.history[len(s.history)-s.maxHistory+1:]...)
+	}
+	s.history = append(s.history, entry)
+}
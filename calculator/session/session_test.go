@@ -0,0 +1,181 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"CalculatorWebService/calculator/expression"
+	"CalculatorWebService/calculator/functions"
+)
+
+func newTestSession(maxHistory int) *Session {
+	return &Session{
+		ID:         "test",
+		variables:  make(map[string]float64),
+		functions:  functions.NewSet(functions.Limits{MaxDepth: 8, MaxSteps: 1000}),
+		history:    make([]Entry, 0),
+		maxHistory: maxHistory,
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name string
+		srcs []string // evaluated in order, only the last one is checked
+		want Result
+		code string // of the EvalError, empty when the last evaluation succeeds
+	}{
+		{"expression", []string{"1 + 2"}, Result{Result: 3, Expression: "1 + 2 = 3"}, ""},
+		{"assignment", []string{"x = 3.5"}, Result{Result: 3.5, Expression: "x = 3.5", Variable: "x"}, ""},
+		{"variables", []string{"x = 2", "y = x * 3", "x + y"}, Result{Result: 8, Expression: "x + y = 8"}, ""},
+		{"ans", []string{"2 * 3", "ans + 1"}, Result{Result: 7, Expression: "ans + 1 = 7"}, ""},
+		{"assignment becomes ans", []string{"x = 4", "ans * 2"}, Result{Result: 8, Expression: "ans * 2 = 8"}, ""},
+		{"MR starts at zero", []string{"MR + 1"}, Result{Result: 1, Expression: "MR + 1 = 1"}, ""},
+		{"no ans yet", []string{"ans + 1"}, Result{}, "unknown_variable"},
+		{"unknown variable", []string{"y + 1"}, Result{}, "unknown_variable"},
+		{"ans is reserved", []string{"ans = 1"}, Result{}, CodeReservedVariable},
+		{"MR is reserved", []string{"MR = 1"}, Result{}, CodeReservedVariable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSession(10)
+			var got Result
+			var err error
+			for _, src := range tt.srcs {
+				got, err = s.Evaluate(src)
+			}
+			var evalErr *expression.EvalError
+			if tt.code != "" {
+				if !errors.As(err, &evalErr) || evalErr.Code != tt.code {
+					t.Fatalf("error = %v, want code %s", err, tt.code)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateKeepsStateOnErrors(t *testing.T) {
+	s := newTestSession(10)
+	if _, err := s.Evaluate("x = 2"); err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range []string{"x = 1 / 0", "x = (", "ans = 5"} {
+		if _, err := s.Evaluate(src); err == nil {
+			t.Fatalf("%q succeeded", src)
+		}
+	}
+	if x, _ := s.Lookup("x"); x != 2 {
+		t.Errorf("x = %v after failed assignments, want 2", x)
+	}
+	if ans, _ := s.Lookup(AnsVariable); ans != 2 {
+		t.Errorf("ans = %v after failed evaluations, want 2", ans)
+	}
+	if n := len(s.History()); n != 1 {
+		t.Errorf("%d history entries, want only the successful one", n)
+	}
+}
+
+func TestTooManyVariables(t *testing.T) {
+	s := newTestSession(10)
+	for i := 0; i < maxVariables; i++ {
+		if _, err := s.Evaluate(fmt.Sprintf("v%d = %d", i, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var evalErr *expression.EvalError
+	if _, err := s.Evaluate("one_more = 1"); !errors.As(err, &evalErr) || evalErr.Code != CodeTooManyVariables {
+		t.Errorf("new variable over the limit: %v, want %s", err, CodeTooManyVariables)
+	}
+	if _, err := s.Evaluate("v0 = 42"); err != nil {
+		t.Errorf("reassigning at the limit: %v", err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	tests := []struct {
+		name    string
+		ans     string // evaluated before the actions, empty for none
+		actions []MemoryAction
+		want    float64
+		err     error
+	}{
+		{"add", "5", []MemoryAction{MemoryAdd, MemoryAdd}, 10, nil},
+		{"subtract", "5", []MemoryAction{MemorySubtract}, -5, nil},
+		{"clear", "5", []MemoryAction{MemoryAdd, MemoryClear}, 0, nil},
+		{"recall", "5", []MemoryAction{MemoryAdd, MemoryRecall}, 5, nil},
+		{"no ans", "", []MemoryAction{MemoryAdd}, 0, ErrNoAns},
+		{"unknown key", "5", []MemoryAction{"M*"}, 0, ErrUnknownAction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSession(10)
+			if tt.ans != "" {
+				if _, err := s.Evaluate(tt.ans); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var got float64
+			var err error
+			for _, action := range tt.actions {
+				got, err = s.Memory(action)
+			}
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("got %v, %v, want %v, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestMemoryRecallBecomesAns(t *testing.T) {
+	s := newTestSession(10)
+	s.Evaluate("7")
+	s.Memory(MemoryAdd)
+	s.Evaluate("1")
+	s.Memory(MemoryRecall)
+	if result, err := s.Evaluate("ans * 2"); err != nil || result.Result != 14 {
+		t.Errorf("ans * 2 after MR = %v, %v, want 14", result.Result, err)
+	}
+}
+
+// Regression: M+ past the float64 range stored +Inf, which then failed every JSON response
+func TestMemoryOverflow(t *testing.T) {
+	s := newTestSession(10)
+	s.Evaluate(fmt.Sprint(math.MaxFloat64))
+	s.Memory(MemoryAdd)
+	memory, err := s.Memory(MemoryAdd)
+	if !errors.Is(err, ErrMemoryOverflow) || memory != math.MaxFloat64 {
+		t.Errorf("got %v, %v, want the register unchanged and %v", memory, err, ErrMemoryOverflow)
+	}
+	if state := s.state(s.CreatedAt); state.Memory != math.MaxFloat64 {
+		t.Errorf("register = %v, want it unchanged", state.Memory)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	s := newTestSession(3)
+	for i := 1; i <= 5; i++ {
+		if _, err := s.Evaluate(fmt.Sprintf("%d + 0", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history := s.History()
+	if len(history) != 3 {
+		t.Fatalf("%d entries, want the last 3", len(history))
+	}
+	for i, entry := range history {
+		if entry.ID != i+3 || entry.Result != float64(i+3) {
+			t.Errorf("entry %d = %+v, want ID and result %d", i, entry, i+3)
+		}
+	}
+
+	history[0].Result = -1
+	if s.History()[0].Result == -1 {
+		t.Error("History returned the session's own slice")
+	}
+}
@@ -0,0 +1,131 @@
+package calculator
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"CalculatorWebService/calculator/session"
+	"CalculatorWebService/internal/logger"
+	"CalculatorWebService/internal/negotiate"
+	"CalculatorWebService/internal/problem"
+)
+
+type SessionEvaluateRequest struct {
+	Expression string `form:"expression" json:"expression" binding:"required"` // an expression or an assignment like "x = 3.5"
+}
+
+type MemoryRequest struct {
+	Action session.MemoryAction `form:"action" json:"action" binding:"required"` // M+, M-, MR or MC
+}
+
+type MemoryResponse struct {
+	Memory float64 `json:"memory"`
+}
+
+type SessionHistoryResponse struct {
+	History []session.Entry `json:"history"`
+}
+
+// CreateSession starts an interactive session, the returned ID goes into the /sessions/:id URLs
+func (h *Handler) CreateSession(c *gin.Context) {
+	s, err := h.Sessions.Create(c.ClientIP())
+	if errors.Is(err, session.ErrTooManySessions) {
+		problem.Respond(c, problemTooManySessions, err.Error())
+		return
+	}
+	if errors.Is(err, session.ErrSessionLimit) {
+		problem.Respond(c, problemSessionLimit, err.Error())
+		return
+	}
+	if err != nil {
+		logger.LogErrorContext(c.Request.Context(), "Failed to create session", err)
+		problem.Respond(c, problem.Internal, "failed to create session")
+		return
+	}
+	negotiate.Render(c, http.StatusCreated, h.Sessions.State(s))
+}
+
+func (h *Handler) GetSession(c *gin.Context) {
+	s, ok := h.session(c)
+	if !ok {
+		return
+	}
+	negotiate.Render(c, http.StatusOK, h.Sessions.State(s))
+}
+
+func (h *Handler) DeleteSession(c *gin.Context) {
+	if err := h.Sessions.Delete(c.Param("id")); err != nil {
+		problem.Respond(c, problemSessionNotFound, err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SessionEvaluate evaluates an expression that may use the session variables, ans and MR
+func (h *Handler) SessionEvaluate(c *gin.Context) {
+	s, ok := h.session(c)
+	if !ok {
+		return
+	}
+	var req SessionEvaluateRequest
+	if err := negotiate.Bind(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	result, err := evaluate(c.Request.Context(), h, "expression", problemEvaluation, func() (session.Result, error) {
+		return s.Evaluate(req.Expression)
+	})
+	if err != nil {
+		respondError(c, err, problemEvaluation)
+		return
+	}
+	negotiate.Render(c, http.StatusOK, result)
+}
+
+func (h *Handler) SessionMemory(c *gin.Context) {
+	s, ok := h.session(c)
+	if !ok {
+		return
+	}
+	var req MemoryRequest
+	if err := negotiate.Bind(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	memory, err := s.Memory(req.Action)
+	if errors.Is(err, session.ErrNoAns) {
+		problem.Respond(c, problemNoAns, err.Error())
+		return
+	}
+	if errors.Is(err, session.ErrMemoryOverflow) {
+		problem.Respond(c, problemOutOfRange, err.Error())
+		return
+	}
+	if err != nil {
+		problem.Respond(c, problemMemoryAction, err.Error())
+		return
+	}
+	negotiate.Render(c, http.StatusOK, MemoryResponse{Memory: memory})
+}
+
+func (h *Handler) SessionHistory(c *gin.Context) {
+	s, ok := h.session(c)
+	if !ok {
+		return
+	}
+	negotiate.Render(c, http.StatusOK, SessionHistoryResponse{History: s.History()})
+}
+
+// session looks up the session from the URL and replies with 404 when it's gone
+func (h *Handler) session(c *gin.Context) (*session.Session, bool) {
+	s, err := h.Sessions.Get(c.Param("id"))
+	if err != nil {
+		problem.Respond(c, problemSessionNotFound, err.Error())
+		return nil, false
+	}
+	return s, true
+}
@@ -0,0 +1,23 @@
+package storage
+
+import "time"
+
+// Calculation is a single stored calculation. ID is assigned by the storage on Store,
+// IDs grow monotonically so newer calculations always have bigger IDs.
+type Calculation struct {
+	ID         int64         `json:"id"`
+	Timestamp  time.Time     `json:"timestamp"`
+	Operation  string        `json:"operation"`
+	Operands   []string      `json:"operands"`
+	Result     string        `json:"result"`
+	Expression string        `json:"expression"`
+	ClientIP   string        `json:"client_ip,omitempty"`
+	UserAgent  string        `json:"user_agent,omitempty"`
+	RequestID  string        `json:"request_id,omitempty"` // X-Request-ID of the request that made it
+	Duration   time.Duration `json:"duration_ns"`
+}
+
+// String is the legacy representation, e.g. "5 + 3 = 8"
+func (c Calculation) String() string {
+	return c.Expression
+}
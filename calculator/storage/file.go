@@ -2,39 +2,173 @@ package storage
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 )
 
+// SyncPolicy defines when appended records are fsynced to disk.
+// Every Store is written to the file right away, so a crash of the process (SIGKILL, OOM, panic)
+// loses nothing in any mode. The policy only matters for OS crashes and power loss.
+type SyncPolicy string
+
+const (
+	SyncAlways   SyncPolicy = "always"   // fsync after every record
+	SyncInterval SyncPolicy = "interval" // fsync in the background every SyncInterval
+	SyncNone     SyncPolicy = "none"     // leave it to the OS, fsync only on Close
+)
+
+// FileStorage is an append-only log with one JSON encoded Calculation per line.
+// Files written by older versions contain bare expressions ("5 + 3 = 8"), those are still readable.
+//
+// The file is only ever appended to: calculations[:persisted] are known to be in the file and
+// size is the byte offset right after them. Writes never touch what is already persisted,
+// a failed write is cut back to size and retried with the next Store, so nothing is written twice.
 type FileStorage struct {
 	filename     string
-	calculations []string
+	file         *os.File
+	policy       SyncPolicy
+	calculations []Calculation
+	functions    functionSet // kept in a separate file, see functionsFile
+	nextID       int64
+	persisted    int   // number of calculations already written to the file
+	size         int64 // file offset right after the last persisted record
+	unsynced     int   // records written since the last fsync
 	mutex        sync.RWMutex
+
+	syncFile func(*os.File) error // (*os.File).Sync, tests make it fail
+
+	stop chan struct{}
+	done chan struct{}
 }
 
-func NewFileStorage(filename string) (*FileStorage, error) {
+func NewFileStorage(filename string, policy SyncPolicy, interval time.Duration) (*FileStorage, error) {
+	switch policy {
+	case SyncAlways, SyncNone:
+	case SyncInterval:
+		if interval <= 0 {
+			return nil, errors.New("sync interval must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("unknown sync policy '%s'", policy)
+	}
+
 	storage := &FileStorage{
 		filename:     filename,
-		calculations: make([]string, 0),
+		policy:       policy,
+		calculations: make([]Calculation, 0),
+		functions:    make(functionSet),
+		nextID:       1,
+		syncFile:     (*os.File).Sync,
 	}
 
 	// Load existing calculations on startup
 	if err := storage.load(); err != nil {
 		return nil, err
 	}
+	if err := storage.loadFunctions(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	storage.file = file
+	storage.size = info.Size()
+	storage.persisted = len(storage.calculations)
+
+	if policy == SyncInterval {
+		storage.stop = make(chan struct{})
+		storage.done = make(chan struct{})
+		go storage.syncLoop(interval)
+	}
 
 	return storage, nil
 }
 
-func (f *FileStorage) Store(calc string) {
+// Store is acknowledged only after the record reached the file, a failed write is rolled back
+// so the caller never sees an ID that isn't persisted. Under SyncAlways the same goes for a failed fsync.
+func (f *FileStorage) Store(ctx context.Context, calc Calculation) (Calculation, error) {
+	if err := ctx.Err(); err != nil {
+		return Calculation{}, err
+	}
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
+	persisted, size := f.persisted, f.size
+	calc.ID = f.nextID
+	f.nextID++ // IDs of rolled back records are not reused, gaps are fine
 	f.calculations = append(f.calculations, calc)
-	return
+
+	if err := f.writePending(); err != nil {
+		f.calculations = f.calculations[:f.persisted]
+		return Calculation{}, fmt.Errorf("write %s: %w", f.filename, err)
+	}
+	if f.policy == SyncAlways {
+		if err := f.sync(); err != nil {
+			return Calculation{}, f.unwrite(persisted, size, fmt.Errorf("fsync %s: %w", f.filename, err))
+		}
+	}
+	return calc, nil
+}
+
+// StoreBatch appends all records with a single write and, under SyncAlways, a single fsync.
+func (f *FileStorage) StoreBatch(ctx context.Context, calcs []Calculation) ([]Calculation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	persisted, size := f.persisted, f.size
+	stored := make([]Calculation, len(calcs))
+	for i, calc := range calcs {
+		calc.ID = f.nextID
+		f.nextID++
+		stored[i] = calc
+	}
+	f.calculations = append(f.calculations, stored...)
+
+	if err := f.writePending(); err != nil {
+		f.calculations = f.calculations[:f.persisted]
+		return nil, fmt.Errorf("write %s: %w", f.filename, err)
+	}
+	if f.policy == SyncAlways {
+		if err := f.sync(); err != nil {
+			return nil, f.unwrite(persisted, size, fmt.Errorf("fsync %s: %w", f.filename, err))
+		}
+	}
+	return stored, nil
 }
 
-func (f *FileStorage) GetRecent(n int) []string {
+func (f *FileStorage) Query(ctx context.Context, query Query) (Page, error) {
+	if err := ctx.Err(); err != nil {
+		return Page{}, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return queryCalculations(f.calculations, query)
+}
+
+func (f *FileStorage) GetRecent(ctx context.Context, n int) ([]Calculation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	f.mutex.RLock()
 	defer f.mutex.RUnlock()
 
@@ -43,54 +177,359 @@ func (f *FileStorage) GetRecent(n int) []string {
 		start = 0
 	}
 	// we don't want to return the original slice to avoid external modification
-	calcCopy := make([]string, len(f.calculations[start:]))
+	calcCopy := make([]Calculation, len(f.calculations[start:]))
 	for i := 0; i < len(calcCopy); i++ {
 		calcCopy[i] = f.calculations[start+i]
 	}
-	return calcCopy
+	return calcCopy, nil
+}
+
+// Unflushed reports how many records are not durable yet: not written at all or written but not fsynced.
+func (f *FileStorage) Unflushed() int {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return len(f.calculations) - f.persisted + f.unsynced
+}
+
+// Size counts the bytes written so far, records waiting for the next write are not in the file yet
+func (f *FileStorage) Size(ctx context.Context) (Size, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return Size{Calculations: int64(len(f.calculations)), Bytes: f.size}, nil
+}
+
+// CheckHealth checks the open file is still usable and the file can be opened for writing,
+// which fails on a read-only file system or after the permissions changed
+func (f *FileStorage) CheckHealth(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	if _, err := f.file.Stat(); err != nil {
+		return fmt.Errorf("stat %s: %w", f.filename, err)
+	}
+	probe, err := os.OpenFile(f.filename, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("open %s for writing: %w", f.filename, err)
+	}
+	return probe.Close()
+}
+
+// Path is the history file, the functions are next to it
+func (f *FileStorage) Path() string {
+	return f.filename
+}
+
+// writePending appends every calculation after the persisted offset. All pending records go out
+// with a single write call, so a crash can tear at most the last line, and a failed write leaves
+// none of them persisted.
+// Must be called with the lock held.
+func (f *FileStorage) writePending() error {
+	if f.persisted == len(f.calculations) {
+		return nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf) // Encode terminates every record with a newline
+	for _, calc := range f.calculations[f.persisted:] {
+		if err := encoder.Encode(calc); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(buf.Bytes())
+	if err != nil {
+		if n > 0 {
+			// drop the partial records, so the retry starts on a clean line
+			_ = f.file.Truncate(f.size)
+		}
+		return err
+	}
+	f.size += int64(n)
+	f.unsynced += len(f.calculations) - f.persisted
+	f.persisted = len(f.calculations)
+	return nil
+}
+
+// unwrite takes back the records written after persisted and size once their fsync failed: the caller gets
+// an error, so they must neither be served nor come back after a restart. If the file can't be cut back
+// they are kept, the history in memory has to match the file. Must be called with the lock held.
+func (f *FileStorage) unwrite(persisted int, size int64, err error) error {
+	if truncErr := f.file.Truncate(size); truncErr != nil {
+		return fmt.Errorf("%w, the records stay in the file: %v", err, truncErr)
+	}
+	f.unsynced -= f.persisted - persisted
+	f.calculations = f.calculations[:persisted]
+	f.persisted, f.size = persisted, size
+	return err
+}
+
+// sync must be called with the lock held
+func (f *FileStorage) sync() error {
+	if f.unsynced == 0 {
+		return nil
+	}
+	if err := f.syncFile(f.file); err != nil {
+		return err
+	}
+	f.unsynced = 0
+	return nil
+}
+
+func (f *FileStorage) syncLoop(interval time.Duration) {
+	defer close(f.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.mutex.Lock()
+			// a failure is retried on the next tick and on Close, meanwhile Unflushed keeps growing
+			_ = f.sync()
+			f.mutex.Unlock()
+		case <-f.stop:
+			return
+		}
+	}
 }
 
+// load reads the existing history and normalizes the file when needed
 func (f *FileStorage) load() error {
+	needsRewrite, err := f.replay()
+	if err != nil {
+		return err
+	}
+	if needsRewrite {
+		return f.rewrite()
+	}
+	return nil
+}
+
+// replay reads the log. The last line may be torn by a crash in the middle of a write, it's dropped.
+// Corruption anywhere else is reported, we don't want to silently lose history.
+// needsRewrite is set when the file has to be normalized: a torn tail was dropped
+// or legacy lines got their IDs assigned and must be persisted with them.
+func (f *FileStorage) replay() (needsRewrite bool, err error) {
 	file, err := os.Open(f.filename)
 	if os.IsNotExist(err) {
 		// File doesn't exist yet - that's OK
-		return nil
+		return false, nil
 	}
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer file.Close()
 
-	f.calculations = make([]string, 0)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
+	f.calculations = make([]Calculation, 0)
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		raw, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return false, readErr
+		}
+
+		line := strings.TrimSpace(string(raw))
 		if line != "" { // Skip empty lines
-			f.calculations = append(f.calculations, line)
+			calc, legacy, ok := parseLine(line)
+			switch {
+			case !ok && readErr == io.EOF:
+				needsRewrite = true // torn tail
+			case !ok:
+				return false, fmt.Errorf("%s: corrupted record on line %d", f.filename, lineNumber)
+			default:
+				// a record without its newline is complete but has to be terminated before appending
+				needsRewrite = needsRewrite || legacy || readErr == io.EOF
+				f.calculations = append(f.calculations, calc)
+			}
 		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	// legacy lines have no ID, they are numbered in file order
+	for i := range f.calculations {
+		if f.calculations[i].ID == 0 {
+			f.calculations[i].ID = f.nextID
+		}
+		if f.calculations[i].ID >= f.nextID {
+			f.nextID = f.calculations[i].ID + 1
+		}
+	}
+	return needsRewrite, nil
+}
+
+// rewrite atomically replaces the file with the loaded calculations
+func (f *FileStorage) rewrite() error {
+	return writeAtomic(f.filename, func(w io.Writer) error {
+		encoder := json.NewEncoder(w) // Encode terminates every record with a newline
+		for _, calc := range f.calculations {
+			if err := encoder.Encode(calc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeAtomic replaces the file with whatever write produces: temp file, fsync, rename.
+// A crash at any point leaves either the old or the new file, never a mix of both.
+func writeAtomic(filename string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	writer := bufio.NewWriter(tmp)
+	if err := write(writer); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return err
 	}
 
-	return scanner.Err()
+	// persist the rename itself
+	if dir, err := os.Open(filepath.Dir(filename)); err == nil {
+		_ = dir.Sync()
+		dir.Close()
+	}
+	return nil
 }
 
+// parseLine reads a JSON record, anything not looking like JSON is treated as a legacy bare expression
+func parseLine(line string) (calc Calculation, legacy bool, ok bool) {
+	if strings.HasPrefix(line, "{") {
+		if err := json.Unmarshal([]byte(line), &calc); err != nil {
+			return Calculation{}, false, false
+		}
+		return calc, false, true
+	}
+	calc = Calculation{Expression: line, Operands: []string{}}
+	if i := strings.LastIndex(line, " = "); i >= 0 {
+		calc.Result = line[i+len(" = "):]
+	}
+	return calc, true, true
+}
+
+// save writes whatever is still pending after the persisted offset and makes it durable.
+// Only records that are not in the file yet are written, the existing history is never appended again.
 func (f *FileStorage) save() error {
-	file, err := os.OpenFile(f.filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.writePending(); err != nil {
+		return err
+	}
+	return f.sync()
+}
+
+// functionsFile holds the user-defined functions as a single JSON array next to the log.
+// Definitions change rarely, so the whole file is rewritten atomically on every change.
+func (f *FileStorage) functionsFile() string {
+	return f.filename + ".functions.json"
+}
+
+func (f *FileStorage) loadFunctions() error {
+	data, err := os.ReadFile(f.functionsFile())
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	var defs []FunctionDefinition
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return fmt.Errorf("%s: %w", f.functionsFile(), err)
+	}
+	for _, def := range defs {
+		f.functions.save(def)
+	}
+	return nil
+}
 
-	writer := bufio.NewWriter(file)
-	for _, calc := range f.calculations {
-		_, err := writer.WriteString(calc + "\n")
-		if err != nil {
-			return err
+// writeFunctions must be called with the lock held
+func (f *FileStorage) writeFunctions() error {
+	return writeAtomic(f.functionsFile(), func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(f.functions.all())
+	})
+}
+
+// SaveFunction is acknowledged once the functions file was replaced, a failed write changes nothing
+func (f *FileStorage) SaveFunction(ctx context.Context, def FunctionDefinition) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	previous, existed := f.functions[def.Scope][def.Name]
+	f.functions.save(def)
+	if err := f.writeFunctions(); err != nil {
+		if existed {
+			f.functions.save(previous)
+		} else {
+			f.functions.remove(def.Scope, def.Name)
 		}
+		return fmt.Errorf("write %s: %w", f.functionsFile(), err)
 	}
+	return nil
+}
 
-	return writer.Flush()
+func (f *FileStorage) DeleteFunction(ctx context.Context, scope, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	previous, existed := f.functions[scope][name]
+	if !existed {
+		return ErrFunctionNotFound
+	}
+	f.functions.remove(scope, name)
+	if err := f.writeFunctions(); err != nil {
+		f.functions.save(previous)
+		return fmt.Errorf("write %s: %w", f.functionsFile(), err)
+	}
+	return nil
+}
+
+func (f *FileStorage) ListFunctions(ctx context.Context, scope string) ([]FunctionDefinition, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.functions.list(scope), nil
 }
 
 func (f *FileStorage) Close() error {
-	return f.save() // Ensure data is saved when closing
+	if f.stop != nil {
+		close(f.stop)
+		<-f.done
+	}
+	err := f.save() // Ensure data is on disk when closing
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
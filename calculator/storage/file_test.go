@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCalculation(i int) Calculation {
+	return Calculation{
+		Timestamp:  time.Date(2025, 1, 1, 12, 0, i, 0, time.UTC),
+		Operation:  "addition",
+		Operands:   []string{fmt.Sprint(i), "1"},
+		Result:     fmt.Sprint(i + 1),
+		Expression: fmt.Sprintf("%d + 1 = %d", i, i+1),
+	}
+}
+
+// fileLines returns the non-empty lines of the file
+func fileLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func openFileStorage(t *testing.T, path string) *FileStorage {
+	t.Helper()
+	storage, err := NewFileStorage(path, SyncNone, 0)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	return storage
+}
+
+// Regression: Close used to append the whole history again, the file doubled with every restart
+func TestFileStorageRestartsDoNotDuplicate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.txt")
+
+	const restarts, perRun = 5, 3
+	stored := 0
+	for run := 0; run < restarts; run++ {
+		storage := openFileStorage(t, path)
+		if got, err := storage.GetRecent(ctx, 1000); err != nil || len(got) != stored {
+			t.Fatalf("run %d: loaded %d calculations (err %v), want %d", run, len(got), err, stored)
+		}
+		for i := 0; i < perRun; i++ {
+			if _, err := storage.Store(ctx, newCalculation(stored)); err != nil {
+				t.Fatalf("run %d: store: %v", run, err)
+			}
+			stored++
+		}
+		if err := storage.Close(); err != nil {
+			t.Fatalf("run %d: close: %v", run, err)
+		}
+		if lines := fileLines(t, path); len(lines) != stored {
+			t.Fatalf("run %d: file has %d lines, want %d", run, len(lines), stored)
+		}
+	}
+
+	storage := openFileStorage(t, path)
+	defer storage.Close()
+	calcs, err := storage.GetRecent(ctx, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, calc := range calcs {
+		if calc.ID != int64(i+1) || calc.Expression != newCalculation(i).Expression {
+			t.Errorf("calculation %d = {ID: %d, Expression: %q}, want {ID: %d, Expression: %q}",
+				i, calc.ID, calc.Expression, i+1, newCalculation(i).Expression)
+		}
+	}
+}
+
+func TestFileStorageReplay(t *testing.T) {
+	record := func(i int) string {
+		calc := newCalculation(i)
+		calc.ID = int64(i + 1)
+		data, err := json.Marshal(calc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    int // calculations loaded, the file is expected to hold exactly these lines afterwards
+		wantErr bool
+	}{
+		{name: "empty", content: "", want: 0},
+		{name: "complete", content: record(0) + "\n" + record(1) + "\n", want: 2},
+		{name: "torn tail", content: record(0) + "\n" + record(1) + "\n" + record(2)[:20], want: 2},
+		{name: "last record without newline", content: record(0) + "\n" + record(1), want: 2},
+		{name: "legacy lines", content: "5 + 3 = 8\n2 * 2 = 4\n", want: 2},
+		{name: "corrupted middle", content: record(0) + "\n{\"id\": 2, broken\n" + record(2) + "\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "storage.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			storage, err := NewFileStorage(path, SyncNone, 0)
+			if tt.wantErr {
+				if err == nil {
+					storage.Close()
+					t.Fatal("corrupted file was loaded without an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			calcs, err := storage.GetRecent(ctx, 1000)
+			if err != nil || len(calcs) != tt.want {
+				t.Fatalf("loaded %d calculations (err %v), want %d", len(calcs), err, tt.want)
+			}
+
+			// the next record must land on a line of its own, after the replayed ones
+			stored, err := storage.Store(ctx, newCalculation(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.ID != int64(tt.want+1) {
+				t.Errorf("stored ID = %d, want %d", stored.ID, tt.want+1)
+			}
+			if err := storage.Close(); err != nil {
+				t.Fatal(err)
+			}
+			lines := fileLines(t, path)
+			if len(lines) != tt.want+1 {
+				t.Fatalf("file has %d lines, want %d:\n%s", len(lines), tt.want+1, strings.Join(lines, "\n"))
+			}
+			for i, line := range lines {
+				var calc Calculation
+				if err := json.Unmarshal([]byte(line), &calc); err != nil || calc.ID != int64(i+1) {
+					t.Errorf("line %d = %q, want a record with ID %d", i+1, line, i+1)
+				}
+			}
+		})
+	}
+}
+
+// Regression: under SyncAlways a failed fsync was reported, but the records stayed in the history and the file
+func TestFileStorageRollsBackFailedSync(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.txt")
+	storage, err := NewFileStorage(path, SyncAlways, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Store(ctx, newCalculation(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	errSync := errors.New("disk is gone")
+	storage.syncFile = func(*os.File) error { return errSync }
+	if _, err := storage.Store(ctx, newCalculation(1)); !errors.Is(err, errSync) {
+		t.Errorf("Store: got %v, want %v", err, errSync)
+	}
+	if _, err := storage.StoreBatch(ctx, []Calculation{newCalculation(1), newCalculation(2)}); !errors.Is(err, errSync) {
+		t.Errorf("StoreBatch: got %v, want %v", err, errSync)
+	}
+	if calcs, _ := storage.GetRecent(ctx, 1000); len(calcs) != 1 {
+		t.Errorf("history has %d calculations, want 1", len(calcs))
+	}
+	if n := storage.Unflushed(); n != 0 {
+		t.Errorf("%d unflushed records, want 0", n)
+	}
+
+	storage.syncFile = (*os.File).Sync
+	stored, err := storage.Store(ctx, newCalculation(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := fileLines(t, path)
+	if len(lines) != 2 {
+		t.Fatalf("file has %d lines, want 2:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	var last Calculation
+	if err := json.Unmarshal([]byte(lines[1]), &last); err != nil || last.ID != stored.ID || last.Expression != newCalculation(3).Expression {
+		t.Errorf("last line = %q, want the record with ID %d", lines[1], stored.ID)
+	}
+}
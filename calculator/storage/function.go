@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrFunctionNotFound = errors.New("function not found")
+
+// FunctionDefinition is a user-defined function as stored, Definition is its source, e.g. "f(x, y) = x^2 + 3*y".
+// Scope separates the functions of different tenants.
+type FunctionDefinition struct {
+	Scope      string    `json:"scope"`
+	Name       string    `json:"name"`
+	Definition string    `json:"definition"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FunctionStore is implemented by backends that persist user-defined functions.
+// Without it functions only live as long as the process.
+type FunctionStore interface {
+	// SaveFunction stores the definition, replacing one with the same scope and name
+	SaveFunction(ctx context.Context, def FunctionDefinition) error
+	// DeleteFunction returns ErrFunctionNotFound when there is nothing to delete
+	DeleteFunction(ctx context.Context, scope, name string) error
+	// ListFunctions returns the definitions of a scope sorted by name
+	ListFunctions(ctx context.Context, scope string) ([]FunctionDefinition, error)
+}
+
+// functionSet is the in-memory FunctionStore state shared by the memory and file backends, keyed by scope and name
+type functionSet map[string]map[string]FunctionDefinition
+
+func (s functionSet) save(def FunctionDefinition) {
+	if s[def.Scope] == nil {
+		s[def.Scope] = make(map[string]FunctionDefinition)
+	}
+	s[def.Scope][def.Name] = def
+}
+
+func (s functionSet) remove(scope, name string) error {
+	if _, ok := s[scope][name]; !ok {
+		return ErrFunctionNotFound
+	}
+	delete(s[scope], name)
+	if len(s[scope]) == 0 {
+		delete(s, scope)
+	}
+	return nil
+}
+
+func (s functionSet) list(scope string) []FunctionDefinition {
+	defs := make([]FunctionDefinition, 0, len(s[scope]))
+	for _, def := range s[scope] {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// all returns every definition, ordered by scope and name so the file backend writes stable output
+func (s functionSet) all() []FunctionDefinition {
+	scopes := make([]string, 0, len(s))
+	for scope := range s {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	defs := make([]FunctionDefinition, 0)
+	for _, scope := range scopes {
+		defs = append(defs, s.list(scope)...)
+	}
+	return defs
+}
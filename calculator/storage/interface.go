@@ -1,21 +1,155 @@
 package storage
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage is the calculation history backend. Implementations must be safe for concurrent use.
+// Backends living outside of this package plug in through Register.
 type Storage interface {
-	Store(string)
-	GetRecent(int) []string
-	save() error
-	load() error
+	// Store persists the calculation and returns it with the ID assigned by the backend
+	Store(ctx context.Context, calc Calculation) (Calculation, error)
+	// GetRecent returns up to n latest calculations, oldest first
+	GetRecent(ctx context.Context, n int) ([]Calculation, error)
+	// Close flushes whatever is pending and releases resources
 	Close() error
 }
 
-func NewStorage(storageType, filename string) (Storage, error) {
-	// creating const for single use seems unnecessary
-	switch storageType {
-	case "file":
-		return NewFileStorage(filename)
-	case "memory":
+// Querier is implemented by backends that can filter and page through the history, the history endpoint
+// and the replay of the live feed need it. Errors caused by the query wrap ErrInvalidQuery.
+type Querier interface {
+	Query(ctx context.Context, query Query) (Page, error)
+}
+
+// ErrQueryUnsupported is returned by QueryHistory when the backend is not a Querier
+var ErrQueryUnsupported = errors.New("history queries are not supported by this storage backend")
+
+// QueryHistory runs the query on backends that support it, it fails with ErrQueryUnsupported on the others
+func QueryHistory(ctx context.Context, storage Storage, query Query) (Page, error) {
+	querier, ok := storage.(Querier)
+	if !ok {
+		return Page{}, ErrQueryUnsupported
+	}
+	return querier.Query(ctx, query)
+}
+
+// FlushReporter is implemented by backends that acknowledge writes before they are durable,
+// the number of such records is exported as a metric.
+type FlushReporter interface {
+	Unflushed() int
+}
+
+// SizeReporter is implemented by backends that can tell how big the history is, exported as metrics
+type SizeReporter interface {
+	Size(ctx context.Context) (Size, error)
+}
+
+// Size of the stored history, Bytes is what it takes on disk and 0 for backends without a disk
+type Size struct {
+	Calculations int64
+	Bytes        int64
+}
+
+// HealthChecker is implemented by backends that can fail to accept writes while reads still work,
+// CheckHealth returns an error when a Store would fail now. It must not change the history.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// BatchStorer is implemented by backends that can persist many calculations in one round-trip.
+// StoreBatch is all or nothing: either every calculation is stored or none is.
+type BatchStorer interface {
+	StoreBatch(ctx context.Context, calcs []Calculation) ([]Calculation, error)
+}
+
+// StoreBatch stores the calculations in one go when the backend supports it,
+// otherwise one by one - then a failure may leave the first calculations stored.
+func StoreBatch(ctx context.Context, storage Storage, calcs []Calculation) ([]Calculation, error) {
+	if batcher, ok := storage.(BatchStorer); ok {
+		return batcher.StoreBatch(ctx, calcs)
+	}
+	stored := make([]Calculation, 0, len(calcs))
+	for _, calc := range calcs {
+		calc, err := storage.Store(ctx, calc)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, calc)
+	}
+	return stored, nil
+}
+
+// Options carry everything a backend may need, each backend picks what is relevant to it
+type Options struct {
+	Type         string
+	FilePath     string
+	SyncPolicy   SyncPolicy
+	SyncInterval time.Duration
+}
+
+// Factory creates a backend from the options, it should fail on options it can't work with.
+type Factory func(options Options) (Storage, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a backend available to NewStorage under the given name (CALCULATOR_STORAGE_TYPE).
+// Like database/sql.Register it's meant to be called from init and panics on duplicates.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("storage: Register factory is nil for " + name)
+	}
+	if _, exists := factories[name]; exists {
+		panic("storage: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// Registered returns names of all registered backends, sorted
+func Registered() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("memory", func(Options) (Storage, error) {
 		return NewMemoryStorage(), nil
-	default:
-		return nil, nil
+	})
+	Register("file", func(options Options) (Storage, error) {
+		return NewFileStorage(options.FilePath, options.SyncPolicy, options.SyncInterval)
+	})
+	Register("sqlite", func(options Options) (Storage, error) {
+		return NewSQLiteStorage(options.FilePath)
+	})
+}
+
+func NewStorage(options Options) (Storage, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[options.Type]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage type '%s', available: %s", options.Type, strings.Join(Registered(), ", "))
+	}
+
+	storage, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("storage '%s': %w", options.Type, err)
 	}
+	return storage, nil
 }
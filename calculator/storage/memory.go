@@ -1,27 +1,69 @@
 package storage
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 type MemoryStorage struct {
-	calculations []string
+	calculations []Calculation
+	functions    functionSet
+	nextID       int64
 	mutex        sync.RWMutex
 }
 
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		calculations: make([]string, 0),
+		calculations: make([]Calculation, 0),
+		functions:    make(functionSet),
+		nextID:       1,
 	}
 }
 
-func (m *MemoryStorage) Store(calc string) {
+func (m *MemoryStorage) Store(ctx context.Context, calc Calculation) (Calculation, error) {
+	if err := ctx.Err(); err != nil {
+		return Calculation{}, err
+	}
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	calc.ID = m.nextID
+	m.nextID++
 	m.calculations = append(m.calculations, calc)
-	return
+	return calc, nil
 }
 
-func (m *MemoryStorage) GetRecent(n int) []string {
+func (m *MemoryStorage) StoreBatch(ctx context.Context, calcs []Calculation) ([]Calculation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored := make([]Calculation, len(calcs))
+	for i, calc := range calcs {
+		calc.ID = m.nextID
+		m.nextID++
+		stored[i] = calc
+	}
+	m.calculations = append(m.calculations, stored...)
+	return stored, nil
+}
+
+func (m *MemoryStorage) Query(ctx context.Context, query Query) (Page, error) {
+	if err := ctx.Err(); err != nil {
+		return Page{}, err
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return queryCalculations(m.calculations, query)
+}
+
+func (m *MemoryStorage) GetRecent(ctx context.Context, n int) ([]Calculation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
@@ -30,13 +72,45 @@ func (m *MemoryStorage) GetRecent(n int) []string {
 		start = 0
 	}
 	// we don't want to return the original slice to avoid external modification
-	calcCopy := make([]string, len(m.calculations[start:]))
+	calcCopy := make([]Calculation, len(m.calculations[start:]))
 	for i := 0; i < len(calcCopy); i++ {
 		calcCopy[i] = m.calculations[start+i]
 	}
-	return calcCopy
+	return calcCopy, nil
+}
+
+func (m *MemoryStorage) Size(ctx context.Context) (Size, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return Size{Calculations: int64(len(m.calculations))}, nil
+}
+
+func (m *MemoryStorage) SaveFunction(ctx context.Context, def FunctionDefinition) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.functions.save(def)
+	return nil
+}
+
+func (m *MemoryStorage) DeleteFunction(ctx context.Context, scope, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.functions.remove(scope, name)
+}
+
+func (m *MemoryStorage) ListFunctions(ctx context.Context, scope string) ([]FunctionDefinition, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.functions.list(scope), nil
 }
 
-func (m *MemoryStorage) save() error  { return nil } // Nothing to save for memory storage
-func (m *MemoryStorage) load() error  { return nil } // Nothing to load for memory storage
 func (m *MemoryStorage) Close() error { return nil } // Nothing to close for memory storage
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SortOrder string
+
+const (
+	SortAscending  SortOrder = "asc"  // oldest first
+	SortDescending SortOrder = "desc" // newest first
+)
+
+// ErrInvalidQuery is wrapped by every error caused by the query itself rather than by the backend
+var (
+	ErrInvalidQuery  = errors.New("invalid query")
+	ErrInvalidCursor = fmt.Errorf("%w: invalid cursor", ErrInvalidQuery)
+)
+
+// Query filters the calculation history. Zero values mean "no filter".
+type Query struct {
+	Operations []string  // matches any of them
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+	MinResult  *float64  // inclusive, non-numeric results never match a result range
+	MaxResult  *float64  // inclusive
+	Search     string    // case-insensitive substring of the expression
+	RequestID  string    // calculations made by a single request
+	Order      SortOrder // defaults to SortDescending
+	Limit      int       // page size, must be positive
+	Cursor     string    // NextCursor of the previous page, empty for the first page
+}
+
+// Page is a single page of a history query, NextCursor is empty on the last page.
+type Page struct {
+	Calculations []Calculation `json:"calculations"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+}
+
+// Cursors are opaque to clients: they hold the order and the ID of the last returned calculation.
+// IDs grow monotonically, so "after this ID" is stable even when new calculations arrive between pages.
+// NewCursor lets callers that know an ID resume from it, e.g. to follow the history as it grows.
+func NewCursor(order SortOrder, id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(string(order) + ":" + strconv.FormatInt(id, 10)))
+}
+
+// decodeCursor returns the ID to continue after, 0 for the first page
+func decodeCursor(cursor string, order SortOrder) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	cursorOrder, idStr, found := strings.Cut(string(raw), ":")
+	if !found || SortOrder(cursorOrder) != order {
+		// a cursor from a differently ordered query would silently skip or repeat records
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+func (q Query) normalized() (Query, error) {
+	if q.Order == "" {
+		q.Order = SortDescending
+	}
+	if q.Order != SortAscending && q.Order != SortDescending {
+		return q, fmt.Errorf("%w: order must be 'asc' or 'desc'", ErrInvalidQuery)
+	}
+	if q.Limit <= 0 {
+		return q, fmt.Errorf("%w: limit must be positive", ErrInvalidQuery)
+	}
+	return q, nil
+}
+
+// matches checks every filter except the cursor
+func (q Query) matches(calc Calculation) bool {
+	if len(q.Operations) > 0 {
+		found := false
+		for _, op := range q.Operations {
+			if calc.Operation == op {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !q.From.IsZero() && calc.Timestamp.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !calc.Timestamp.Before(q.To) {
+		return false
+	}
+	if q.MinResult != nil || q.MaxResult != nil {
+		result, err := strconv.ParseFloat(calc.Result, 64)
+		if err != nil {
+			return false
+		}
+		if q.MinResult != nil && result < *q.MinResult {
+			return false
+		}
+		if q.MaxResult != nil && result > *q.MaxResult {
+			return false
+		}
+	}
+	if q.Search != "" && !strings.Contains(strings.ToLower(calc.Expression), strings.ToLower(q.Search)) {
+		return false
+	}
+	if q.RequestID != "" && calc.RequestID != q.RequestID {
+		return false
+	}
+	return true
+}
+
+// queryCalculations runs the query over calculations sorted by ID ascending,
+// it is shared by the backends that keep the whole history in memory.
+func queryCalculations(calculations []Calculation, q Query) (Page, error) {
+	q, err := q.normalized()
+	if err != nil {
+		return Page{}, err
+	}
+	after, err := decodeCursor(q.Cursor, q.Order)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Calculations: make([]Calculation, 0, q.Limit)}
+	// collect one extra record to know whether there is a next page
+	take := func(calc Calculation) bool {
+		if !q.matches(calc) {
+			return true
+		}
+		if len(page.Calculations) == q.Limit {
+			last := page.Calculations[len(page.Calculations)-1]
+			page.NextCursor = NewCursor(q.Order, last.ID)
+			return false
+		}
+		page.Calculations = append(page.Calculations, calc)
+		return true
+	}
+
+	if q.Order == SortAscending {
+		start := 0
+		if after > 0 {
+			start = sort.Search(len(calculations), func(i int) bool { return calculations[i].ID > after })
+		}
+		for i := start; i < len(calculations); i++ {
+			if !take(calculations[i]) {
+				break
+			}
+		}
+	} else {
+		end := len(calculations)
+		if after > 0 {
+			end = sort.Search(len(calculations), func(i int) bool { return calculations[i].ID >= after })
+		}
+		for i := end - 1; i >= 0; i-- {
+			if !take(calculations[i]) {
+				break
+			}
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,399 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // pure Go driver, keeps the CGO_ENABLED=0 build working
+)
+
+// migrations are applied in order and recorded in schema_migrations, never edit an applied one - append a new one.
+var migrations = []string{
+	`CREATE TABLE calculations (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at  INTEGER NOT NULL, -- unix nanoseconds, integers keep range queries on the index cheap
+		operation   TEXT    NOT NULL,
+		operands    TEXT    NOT NULL, -- JSON array
+		result      TEXT    NOT NULL,
+		expression  TEXT    NOT NULL,
+		client_ip   TEXT    NOT NULL DEFAULT '',
+		user_agent  TEXT    NOT NULL DEFAULT '',
+		duration_ns INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX idx_calculations_created_at ON calculations (created_at);
+	CREATE INDEX idx_calculations_operation ON calculations (operation, created_at);`,
+	// numeric copy of the result for range filters. CAST turns results that are not a number into 0,
+	// migration 5 sets them back to NULL.
+	`ALTER TABLE calculations ADD COLUMN result_value REAL;
+	UPDATE calculations SET result_value = CAST(result AS REAL);`,
+	`CREATE TABLE functions (
+		scope      TEXT    NOT NULL,
+		name       TEXT    NOT NULL,
+		definition TEXT    NOT NULL,
+		created_at INTEGER NOT NULL, -- unix nanoseconds
+		PRIMARY KEY (scope, name)
+	);`,
+	`ALTER TABLE calculations ADD COLUMN request_id TEXT NOT NULL DEFAULT '';
+	CREATE INDEX idx_calculations_request_id ON calculations (request_id) WHERE request_id != '';`,
+	// recomputed by backfillResultValue with the parsing of insert, NULL when the result is not a number
+	`UPDATE calculations SET result_value = NULL;`,
+}
+
+// backfills convert data SQL can't, they run after the migration of the same version in its transaction
+var backfills = map[int]func(tx *sql.Tx) error{
+	5: backfillResultValue,
+}
+
+// SQLiteStorage persists calculations in a SQLite database, so the history can be queried with SQL.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	// the path is escaped, a ? or # in it would otherwise start the query or the fragment of the URI
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer anyway, one connection avoids SQLITE_BUSY between our own goroutines
+	db.SetMaxOpenConns(1)
+
+	storage := &SQLiteStorage{db: db}
+	if err := storage.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite migration failed: %w", err)
+	}
+	return storage, nil
+}
+
+func (s *SQLiteStorage) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at INTEGER NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("version %d: %w", version, err)
+		}
+		if backfill, ok := backfills[version]; ok {
+			if err := backfill(tx); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("version %d: %w", version, err)
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			version, time.Now().UTC().UnixNano()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillResultValue sets result_value of the numeric results, the others stay NULL like in insert.
+// The results are read first, a single connection can't update while it iterates.
+func backfillResultValue(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, result FROM calculations`)
+	if err != nil {
+		return err
+	}
+	values := make(map[int64]float64)
+	for rows.Next() {
+		var id int64
+		var result string
+		if err := rows.Scan(&id, &result); err != nil {
+			rows.Close()
+			return err
+		}
+		if value, err := strconv.ParseFloat(result, 64); err == nil {
+			values[id] = value
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	update, err := tx.Prepare(`UPDATE calculations SET result_value = ? WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	defer update.Close()
+	for id, value := range values {
+		if _, err := update.Exec(value, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const insertCalculation = `INSERT INTO calculations
+	(created_at, operation, operands, result, result_value, expression, client_ip, user_agent, duration_ns, request_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (s *SQLiteStorage) Store(ctx context.Context, calc Calculation) (Calculation, error) {
+	return insert(ctx, s.db, calc)
+}
+
+// StoreBatch inserts all calculations in one transaction
+func (s *SQLiteStorage) StoreBatch(ctx context.Context, calcs []Calculation) ([]Calculation, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	stored := make([]Calculation, len(calcs))
+	for i, calc := range calcs {
+		if stored[i], err = insert(ctx, tx, calc); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func insert(ctx context.Context, db execer, calc Calculation) (Calculation, error) {
+	operands, err := json.Marshal(calc.Operands)
+	if err != nil {
+		return Calculation{}, err
+	}
+	var resultValue sql.NullFloat64
+	if value, err := strconv.ParseFloat(calc.Result, 64); err == nil {
+		resultValue = sql.NullFloat64{Float64: value, Valid: true}
+	}
+	res, err := db.ExecContext(ctx, insertCalculation,
+		calc.Timestamp.UnixNano(), calc.Operation, string(operands), calc.Result, resultValue, calc.Expression,
+		calc.ClientIP, calc.UserAgent, int64(calc.Duration), calc.RequestID)
+	if err != nil {
+		return Calculation{}, err
+	}
+	if calc.ID, err = res.LastInsertId(); err != nil {
+		return Calculation{}, err
+	}
+	return calc, nil
+}
+
+func (s *SQLiteStorage) GetRecent(ctx context.Context, n int) ([]Calculation, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+calculationColumns+` FROM calculations ORDER BY id DESC LIMIT ?`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calculations, err := scanCalculations(rows)
+	if err != nil {
+		return nil, err
+	}
+	// oldest first, same as the other backends
+	for i, j := 0, len(calculations)-1; i < j; i, j = i+1, j-1 {
+		calculations[i], calculations[j] = calculations[j], calculations[i]
+	}
+	return calculations, nil
+}
+
+// Query translates the filters to SQL, the indexes on created_at and operation cover the common cases.
+func (s *SQLiteStorage) Query(ctx context.Context, query Query) (Page, error) {
+	query, err := query.normalized()
+	if err != nil {
+		return Page{}, err
+	}
+	after, err := decodeCursor(query.Cursor, query.Order)
+	if err != nil {
+		return Page{}, err
+	}
+
+	where := make([]string, 0)
+	args := make([]interface{}, 0)
+	if after > 0 {
+		if query.Order == SortAscending {
+			where = append(where, "id > ?")
+		} else {
+			where = append(where, "id < ?")
+		}
+		args = append(args, after)
+	}
+	if len(query.Operations) > 0 {
+		where = append(where, "operation IN (?"+strings.Repeat(", ?", len(query.Operations)-1)+")")
+		for _, op := range query.Operations {
+			args = append(args, op)
+		}
+	}
+	if !query.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, query.From.UnixNano())
+	}
+	if !query.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, query.To.UnixNano())
+	}
+	if query.MinResult != nil {
+		where = append(where, "result_value >= ?")
+		args = append(args, *query.MinResult)
+	}
+	if query.MaxResult != nil {
+		where = append(where, "result_value <= ?")
+		args = append(args, *query.MaxResult)
+	}
+	if query.Search != "" {
+		where = append(where, `expression LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(query.Search)+"%")
+	}
+	if query.RequestID != "" {
+		where = append(where, "request_id = ?")
+		args = append(args, query.RequestID)
+	}
+
+	statement := `SELECT ` + calculationColumns + ` FROM calculations`
+	if len(where) > 0 {
+		statement += " WHERE " + strings.Join(where, " AND ")
+	}
+	if query.Order == SortAscending {
+		statement += " ORDER BY id ASC"
+	} else {
+		statement += " ORDER BY id DESC"
+	}
+	// one extra row tells whether there is a next page
+	statement += " LIMIT ?"
+	args = append(args, query.Limit+1)
+
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return Page{}, err
+	}
+	defer rows.Close()
+
+	calculations, err := scanCalculations(rows)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Calculations: calculations}
+	if len(calculations) > query.Limit {
+		page.Calculations = calculations[:query.Limit]
+		page.NextCursor = NewCursor(query.Order, page.Calculations[query.Limit-1].ID)
+	}
+	return page, nil
+}
+
+func (s *SQLiteStorage) SaveFunction(ctx context.Context, def FunctionDefinition) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO functions (scope, name, definition, created_at) VALUES (?, ?, ?, ?)`,
+		def.Scope, def.Name, def.Definition, def.CreatedAt.UTC().UnixNano())
+	return err
+}
+
+func (s *SQLiteStorage) DeleteFunction(ctx context.Context, scope, name string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM functions WHERE scope = ? AND name = ?`, scope, name)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrFunctionNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) ListFunctions(ctx context.Context, scope string) ([]FunctionDefinition, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT scope, name, definition, created_at FROM functions WHERE scope = ? ORDER BY name`, scope)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defs := make([]FunctionDefinition, 0)
+	for rows.Next() {
+		var def FunctionDefinition
+		var createdAt int64
+		if err := rows.Scan(&def.Scope, &def.Name, &def.Definition, &createdAt); err != nil {
+			return nil, err
+		}
+		def.CreatedAt = time.Unix(0, createdAt).UTC()
+		defs = append(defs, def)
+	}
+	return defs, rows.Err()
+}
+
+const calculationColumns = "id, created_at, operation, operands, result, expression, client_ip, user_agent, duration_ns, request_id"
+
+// LIKE is case-insensitive for ASCII in SQLite, only the wildcards need escaping
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func scanCalculations(rows *sql.Rows) ([]Calculation, error) {
+	calculations := make([]Calculation, 0)
+	for rows.Next() {
+		var calc Calculation
+		var createdAt, duration int64
+		var operands string
+		if err := rows.Scan(&calc.ID, &createdAt, &calc.Operation, &operands, &calc.Result, &calc.Expression,
+			&calc.ClientIP, &calc.UserAgent, &duration, &calc.RequestID); err != nil {
+			return nil, err
+		}
+		calc.Timestamp = time.Unix(0, createdAt).UTC()
+		calc.Duration = time.Duration(duration)
+		if err := json.Unmarshal([]byte(operands), &calc.Operands); err != nil {
+			return nil, err
+		}
+		calculations = append(calculations, calc)
+	}
+	return calculations, rows.Err()
+}
+
+// Size reports the whole database file, functions and indexes included
+func (s *SQLiteStorage) Size(ctx context.Context) (Size, error) {
+	var size Size
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM calculations`).Scan(&size.Calculations); err != nil {
+		return Size{}, err
+	}
+	if err := s.db.QueryRowContext(ctx, `SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()`).Scan(&size.Bytes); err != nil {
+		return Size{}, err
+	}
+	return size, nil
+}
+
+// CheckHealth takes the write lock and releases it right away: it fails when the database is read-only
+// or another process holds the lock for longer than the busy timeout
+func (s *SQLiteStorage) CheckHealth(ctx context.Context) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, "ROLLBACK")
+	return err
+}
+
+func (s *SQLiteStorage) Close() error { return s.db.Close() } // every Store is committed right away, nothing to flush
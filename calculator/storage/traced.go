@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"CalculatorWebService/internal/tracing"
+)
+
+// storage span attributes, the backend is the CALCULATOR_STORAGE_TYPE name
+var (
+	attrBackend = attribute.Key("calculator.storage")
+	attrID      = attribute.Key("calculator.calculation_id")
+	attrCount   = attribute.Key("calculator.calculations")
+)
+
+type tracedStorage struct {
+	Storage
+	backend attribute.KeyValue
+}
+
+// Traced wraps the backend so every call gets its own span, a child of the request's span.
+// Like the other wrappers it only keeps StoreBatch of the optional interfaces, check those on the backend itself.
+func Traced(backend Storage, name string) Storage {
+	return &tracedStorage{Storage: backend, backend: attrBackend.String(name)}
+}
+
+func (t *tracedStorage) Store(ctx context.Context, calc Calculation) (Calculation, error) {
+	ctx, span := tracing.Start(ctx, "storage.Store", t.backend, attribute.String("calculator.operation", calc.Operation))
+	calc, err := t.Storage.Store(ctx, calc)
+	if err == nil {
+		span.SetAttributes(attrID.Int64(calc.ID))
+	}
+	tracing.End(span, err)
+	return calc, err
+}
+
+func (t *tracedStorage) StoreBatch(ctx context.Context, calcs []Calculation) ([]Calculation, error) {
+	ctx, span := tracing.Start(ctx, "storage.StoreBatch", t.backend, attrCount.Int(len(calcs)))
+	stored, err := StoreBatch(ctx, t.Storage, calcs)
+	tracing.End(span, err)
+	return stored, err
+}
+
+func (t *tracedStorage) GetRecent(ctx context.Context, n int) ([]Calculation, error) {
+	ctx, span := tracing.Start(ctx, "storage.GetRecent", t.backend, attribute.Int("calculator.limit", n))
+	calcs, err := t.Storage.GetRecent(ctx, n)
+	if err == nil {
+		span.SetAttributes(attrCount.Int(len(calcs)))
+	}
+	tracing.End(span, err)
+	return calcs, err
+}
+
+func (t *tracedStorage) Query(ctx context.Context, query Query) (Page, error) {
+	ctx, span := tracing.Start(ctx, "storage.Query", t.backend, attribute.Int("calculator.limit", query.Limit))
+	page, err := QueryHistory(ctx, t.Storage, query)
+	if err == nil {
+		span.SetAttributes(attrCount.Int(len(page.Calculations)))
+	}
+	tracing.End(span, err)
+	return page, err
+}
+
+type tracedFunctions struct {
+	FunctionStore
+	backend attribute.KeyValue
+}
+
+// TracedFunctions is Traced for the function definitions, nil stays nil
+func TracedFunctions(store FunctionStore, name string) FunctionStore {
+	if store == nil {
+		return nil
+	}
+	return &tracedFunctions{FunctionStore: store, backend: attrBackend.String(name)}
+}
+
+func (t *tracedFunctions) SaveFunction(ctx context.Context, def FunctionDefinition) error {
+	ctx, span := tracing.Start(ctx, "storage.SaveFunction", t.backend, attribute.String("calculator.function", def.Name))
+	err := t.FunctionStore.SaveFunction(ctx, def)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedFunctions) DeleteFunction(ctx context.Context, scope, name string) error {
+	ctx, span := tracing.Start(ctx, "storage.DeleteFunction", t.backend, attribute.String("calculator.function", name))
+	err := t.FunctionStore.DeleteFunction(ctx, scope, name)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedFunctions) ListFunctions(ctx context.Context, scope string) ([]FunctionDefinition, error) {
+	ctx, span := tracing.Start(ctx, "storage.ListFunctions", t.backend)
+	defs, err := t.FunctionStore.ListFunctions(ctx, scope)
+	if err == nil {
+		span.SetAttributes(attribute.Int("calculator.functions", len(defs)))
+	}
+	tracing.End(span, err)
+	return defs, err
+}
@@ -0,0 +1,228 @@
+package calculator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"CalculatorWebService/calculator/storage"
+	"CalculatorWebService/internal/logger"
+	"CalculatorWebService/internal/problem"
+)
+
+const (
+	// streamHeartbeat keeps idle connections alive through proxies and detects dead websocket clients
+	streamHeartbeat = 15 * time.Second
+	// wsWriteWait bounds a single websocket write, a client that can't take it is disconnected
+	wsWriteWait = 10 * time.Second
+)
+
+// StreamRequest is shared by the SSE and WebSocket feeds
+type StreamRequest struct {
+	Operation []string `form:"operation"` // repeat it or separate with commas, all operations when empty
+	AfterID   int64    `form:"after_id"`  // replay calculations stored after this ID before going live
+}
+
+// StreamMessage is a single WebSocket message, Type is "calculation" or "dropped"
+type StreamMessage struct {
+	Type        string               `json:"type"`
+	Calculation *storage.Calculation `json:"calculation,omitempty"`
+	Dropped     uint64               `json:"dropped,omitempty"` // total events lost by this client so far
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// failed handshakes (wrong method, missing headers, foreign origin) are problems like any other error
+	Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+		p := problemUpgrade.New(reason.Error())
+		p.Status = status
+		problem.WriteHTTP(w, r, p)
+	},
+}
+
+// Stream serves the live feed as Server-Sent Events. Every calculation is sent with its ID as the event ID,
+// so the browser EventSource resumes after a reconnect through the Last-Event-ID header.
+func (h *Handler) Stream(c *gin.Context) {
+	var req StreamRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" && req.AfterID == 0 {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			problem.Respond(c, problem.InvalidRequest, "Last-Event-ID must be a calculation ID")
+			return
+		}
+		req.AfterID = id
+	}
+
+	sub := h.Feed.Subscribe(splitOperations(req.Operation))
+	defer sub.Close()
+
+	// the stream outlives the server write timeout by design
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		logger.LogWarn("Failed to lift the write deadline of the event stream")
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // nginx would buffer the stream otherwise
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	var reported uint64
+	send := func(calc storage.Calculation) error {
+		if dropped := sub.Dropped(); dropped != reported {
+			reported = dropped
+			if err := writeSSE(c, "dropped", "", gin.H{"dropped": dropped}); err != nil {
+				return err
+			}
+		}
+		return writeSSE(c, "calculation", strconv.FormatInt(calc.ID, 10), calc)
+	}
+
+	replayed, err := sub.Replay(ctx, h.Storage, req.AfterID, send)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.LogErrorContext(ctx, "Failed to replay calculations", err)
+		}
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case calc, ok := <-sub.Events():
+			if !ok {
+				return // the feed is shutting down
+			}
+			if calc.ID <= replayed {
+				continue
+			}
+			if err := send(calc); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeSSE(c *gin.Context, event, id string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(c.Writer, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// StreamWebSocket serves the same feed as Stream over a WebSocket, messages are StreamMessage JSON objects.
+// Anything the client sends is ignored, closing the socket ends the subscription.
+func (h *Handler) StreamWebSocket(c *gin.Context) {
+	var req StreamRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has already replied with an error status
+		return
+	}
+	defer conn.Close()
+
+	sub := h.Feed.Subscribe(splitOperations(req.Operation))
+	defer sub.Close()
+
+	// the read pump only handles control frames and notices the client going away
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	})
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var reported uint64
+	send := func(calc storage.Calculation) error {
+		if dropped := sub.Dropped(); dropped != reported {
+			reported = dropped
+			if err := writeWebSocket(conn, StreamMessage{Type: "dropped", Dropped: dropped}); err != nil {
+				return err
+			}
+		}
+		return writeWebSocket(conn, StreamMessage{Type: "calculation", Calculation: &calc})
+	}
+
+	replayed, err := sub.Replay(ctx, h.Storage, req.AfterID, send)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.LogErrorContext(ctx, "Failed to replay calculations", err)
+		}
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case calc, ok := <-sub.Events():
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server is shutting down"), time.Now().Add(wsWriteWait))
+				return
+			}
+			if calc.ID <= replayed {
+				continue
+			}
+			if err := send(calc); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeWebSocket(conn *websocket.Conn, message StreamMessage) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(wsWriteWait)); err != nil {
+		return err
+	}
+	return conn.WriteJSON(message)
+}
@@ -0,0 +1,109 @@
+package calculator
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"CalculatorWebService/calculator/storage"
+)
+
+// CSV exports of the responses (Accept: text/csv), see negotiate.Table.
+// Columns use the JSON field names, lists of names are joined with spaces. Cells are written as they are,
+// negotiate escapes the ones a spreadsheet would take for a formula.
+
+var calculationColumns = []string{"id", "timestamp", "operation", "operands", "result", "expression", "client_ip", "user_agent", "duration_ns", "request_id"}
+
+func calculationRows(calculations []storage.Calculation) [][]string {
+	rows := make([][]string, len(calculations))
+	for i, calc := range calculations {
+		rows[i] = []string{
+			strconv.FormatInt(calc.ID, 10),
+			calc.Timestamp.Format(time.RFC3339Nano),
+			calc.Operation,
+			strings.Join(calc.Operands, " "),
+			calc.Result,
+			calc.Expression,
+			calc.ClientIP,
+			calc.UserAgent,
+			strconv.FormatInt(int64(calc.Duration), 10),
+			calc.RequestID,
+		}
+	}
+	return rows
+}
+
+func (r RecentResponse) CSVHeader() []string { return calculationColumns }
+func (r RecentResponse) CSVRows() [][]string { return calculationRows(r.Records) }
+
+// the next cursor is only in the JSON response, exports are meant for a single page
+func (r HistoryResponse) CSVHeader() []string { return calculationColumns }
+func (r HistoryResponse) CSVRows() [][]string { return calculationRows(r.Calculations) }
+
+func (r Response) CSVHeader() []string {
+	return []string{"operation", "expression", "result", "exact_result", "precision", "unit"}
+}
+
+func (r Response) CSVRows() [][]string {
+	precision := ""
+	if r.Precision > 0 {
+		precision = strconv.Itoa(r.Precision)
+	}
+	return [][]string{{r.Operation, r.Expression, formatFloat(r.Result), r.ExactResult, precision, r.Unit}}
+}
+
+func (r BatchResponse) CSVHeader() []string {
+	return []string{"index", "id", "operation", "expression", "result", "error", "code"}
+}
+
+func (r BatchResponse) CSVRows() [][]string {
+	rows := make([][]string, len(r.Results))
+	for i, result := range r.Results {
+		row := []string{strconv.Itoa(result.Index), "", "", "", "", result.Error, result.Code}
+		if result.Response != nil {
+			row[1] = strconv.FormatInt(result.ID, 10)
+			row[2], row[3], row[4] = result.Operation, result.Expression, formatFloat(result.Result)
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+func (r OperationsResponse) CSVHeader() []string {
+	return []string{"name", "path", "symbol", "notation", "arity", "precise", "angular", "constraints"}
+}
+
+func (r OperationsResponse) CSVRows() [][]string {
+	rows := make([][]string, len(r.Operations))
+	for i, op := range r.Operations {
+		rows[i] = []string{
+			op.Name, op.Path, op.Symbol, string(op.Notation), strconv.Itoa(op.Arity),
+			strconv.FormatBool(op.Precise), strconv.FormatBool(op.Angular), strings.Join(op.Constraints, "; "),
+		}
+	}
+	return rows
+}
+
+func (r SessionHistoryResponse) CSVHeader() []string {
+	return []string{"id", "timestamp", "expression", "result", "variable"}
+}
+
+func (r SessionHistoryResponse) CSVRows() [][]string {
+	rows := make([][]string, len(r.History))
+	for i, entry := range r.History {
+		rows[i] = []string{strconv.Itoa(entry.ID), entry.Timestamp.Format(time.RFC3339Nano), entry.Expression, formatFloat(entry.Result), entry.Variable}
+	}
+	return rows
+}
+
+func (r FunctionsResponse) CSVHeader() []string {
+	return []string{"name", "params", "definition", "created_at"}
+}
+
+func (r FunctionsResponse) CSVRows() [][]string {
+	rows := make([][]string, len(r.Functions))
+	for i, def := range r.Functions {
+		rows[i] = []string{def.Name, strings.Join(def.Params, " "), def.Definition, def.CreatedAt.Format(time.RFC3339Nano)}
+	}
+	return rows
+}
@@ -0,0 +1,41 @@
+package calculator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"CalculatorWebService/calculator/precise"
+	"CalculatorWebService/calculator/storage"
+	"CalculatorWebService/internal/config"
+)
+
+// ValidateConfig checks the calculator settings the config package can't: the storage backend must be
+// registered, the file backends need a path they can write to and the precision is capped by the precise mode.
+// It is meant to be passed to config.Loader.Validate.
+func ValidateConfig(configs config.Configs) []config.Problem {
+	serviceConfig, ok := configs[config.CalculatorConfigKey].(config.CalculatorConfig)
+	if !ok {
+		return nil
+	}
+	var problems []config.Problem
+	if registered := storage.Registered(); !slices.Contains(registered, serviceConfig.StorageType) {
+		problems = append(problems, config.Problem{
+			Key:     "calculator.storage_type",
+			Message: "must be one of " + strings.Join(registered, ", "),
+		})
+	}
+	// memory and custom backends don't use the path
+	if serviceConfig.StorageType == "file" || serviceConfig.StorageType == "sqlite" {
+		if err := config.Writable(serviceConfig.StorageFilePath); err != nil {
+			problems = append(problems, config.Problem{Key: "calculator.storage_path", Message: err.Error()})
+		}
+	}
+	if serviceConfig.Precision > precise.MaxDigits {
+		problems = append(problems, config.Problem{
+			Key:     "calculator.precision",
+			Message: fmt.Sprintf("must not be above %d digits", precise.MaxDigits),
+		})
+	}
+	return problems
+}
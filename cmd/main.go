@@ -2,10 +2,10 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"CalculatorWebService/calculator"
 	"CalculatorWebService/internal/config"
@@ -17,29 +17,64 @@ func main() {
 		config.MetricsConfigKey,
 		config.LoggerConfigKey,
 		config.CalculatorConfigKey,
+		config.TracingConfigKey,
+	}
+	loader, err := config.NewLoader(os.Args[1:])
+	if err != nil {
+		// the logger needs the configuration, this one goes straight to stderr
+		fmt.Fprintln(os.Stderr, "Invalid configuration:", err)
+		os.Exit(2)
+	}
+	configs := loader.LoadConfigs(requiredConfigs)
+	invalid := loader.Validate(configs, calculator.ValidateConfig)
+	if loader.PrintConfig {
+		if err := loader.Print(os.Stdout); err != nil {
+			os.Exit(1)
+		}
+		if invalid != nil {
+			fmt.Fprintln(os.Stderr, invalid)
+			os.Exit(2)
+		}
+		return
+	}
+	if invalid != nil {
+		// nothing is started yet, not even the logger, whose settings may be the broken ones
+		fmt.Fprintln(os.Stderr, invalid)
+		os.Exit(2)
 	}
-	configs := config.LoadConfigs(requiredConfigs)
 
 	logger.InitLogger(configs[config.LoggerConfigKey].(config.LoggerConfig))
 
-	srv := calculator.NewService(configs)
+	srv, err := calculator.NewService(configs)
+	if err != nil {
+		logger.LogError("Failed to start calculator", err)
+		os.Exit(1)
+	}
 
-	go GracefulShutdown(srv)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Start()
+	}()
 
-	if err := srv.Start(); err != nil {
+	// main only returns once the shutdown is over, Start returning is not the end of it
+	select {
+	case <-quit:
+		signal.Stop(quit) // a second signal kills the process without waiting for the drain
+		GracefulShutdown(srv, configs[config.CalculatorConfigKey].(config.CalculatorConfig))
+	case err := <-serveErr:
 		logger.LogError("Server error", err)
+		// the storage may hold acknowledged calculations that are not flushed yet
+		GracefulShutdown(srv, configs[config.CalculatorConfigKey].(config.CalculatorConfig))
+		os.Exit(1)
 	}
-
 }
 
-func GracefulShutdown(srv *calculator.Service) {
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
+func GracefulShutdown(srv *calculator.Service, serviceConfig config.CalculatorConfig) {
 	logger.LogInfo("Shutting down server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), serviceConfig.ShutdownTimeout)
 	defer cancel()
 
 	srv.Shutdown(ctx)
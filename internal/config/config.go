@@ -1,8 +1,7 @@
 package config
 
 import (
-	"os"
-	"strconv"
+	"runtime"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -12,18 +11,42 @@ const (
 	LoggerConfigKey     = "LOG"
 	MetricsConfigKey    = "METRICS"
 	CalculatorConfigKey = "CALCULATOR"
+	TracingConfigKey    = "TRACING"
 )
 
 type Configs map[string]interface{}
 
 type CalculatorConfig struct {
-	Version         string        `json:"version"`
-	StorageType     string        `json:"storage_type"`
-	StorageFilePath string        `json:"storage_file_path"`
-	Port            string        `json:"port"`
-	ReadTimeout     time.Duration `json:"read_timeout"`
-	WriteTimeout    time.Duration `json:"write_timeout"`
-	IdleTimeout     time.Duration `json:"idle_timeout"`
+	Version             string        `json:"version"`
+	StorageType         string        `json:"storage_type"`
+	StorageFilePath     string        `json:"storage_file_path"`
+	StorageSync         string        `json:"storage_sync"` // always, interval or none
+	StorageSyncInterval time.Duration `json:"storage_sync_interval"`
+	Port                string        `json:"port"`
+	GRPCPort            string        `json:"grpc_port"`
+	ReadTimeout         time.Duration `json:"read_timeout"`
+	WriteTimeout        time.Duration `json:"write_timeout"`
+	IdleTimeout         time.Duration `json:"idle_timeout"`
+	Precision           int           `json:"precision"` // significant digits of the precise mode, 0 keeps float64 arithmetic
+	BatchWorkers        int           `json:"batch_workers"`
+	BatchMaxItems       int           `json:"batch_max_items"`
+	MaxBodyBytes        int           `json:"max_body_bytes"`
+	TrustedProxies      []string      `json:"trusted_proxies"`
+	RateLimit           float64       `json:"rate_limit"`
+	RateBurst           int           `json:"rate_burst"`
+	StreamBuffer        int           `json:"stream_buffer"` // events buffered per live feed client before the oldest are dropped
+	SessionTTL          time.Duration `json:"session_ttl"`   // sessions expire after this long without use
+	SessionMax          int           `json:"session_max"`
+	SessionMaxPerClient int           `json:"session_max_per_client"`
+	SessionHistory      int           `json:"session_history"` // evaluations kept per session
+	FunctionMax         int           `json:"function_max"`    // user-defined functions per tenant or session
+	FunctionMaxDepth    int           `json:"function_max_depth"`
+	FunctionMaxSteps    int           `json:"function_max_steps"` // evaluated nodes per expression
+	HealthCacheTTL      time.Duration `json:"health_cache_ttl"`   // how long readiness check results are reused
+	HealthTimeout       time.Duration `json:"health_timeout"`     // per readiness check
+	HealthMinFreeMB     int           `json:"health_min_free_mb"` // free disk space the file storage needs to be ready
+	ShutdownTimeout     time.Duration `json:"shutdown_timeout"`   // deadline of the whole shutdown, in-flight requests are cut after it
+	ShutdownDelay       time.Duration `json:"shutdown_delay"`     // time between failing readiness and closing the listeners
 }
 type LoggerConfig struct {
 	ServerName string `json:"server_name"`
@@ -39,15 +62,27 @@ type MetricsConfig struct {
 	ServerName     string `json:"server_name"`
 }
 
+type TracingConfig struct {
+	ServiceName    string  `json:"service_name"`
+	ServiceVersion string  `json:"service_version"`
+	ServerName     string  `json:"server_name"`
+	Exporter       string  `json:"exporter"`      // none, otlp, stdout or file
+	OTLPEndpoint   string  `json:"otlp_endpoint"` // host:port, empty uses OTEL_EXPORTER_OTLP_ENDPOINT or the exporter default
+	OTLPProtocol   string  `json:"otlp_protocol"` // grpc or http
+	OTLPInsecure   bool    `json:"otlp_insecure"`
+	FilePath       string  `json:"file_path"`    // spans are appended as JSON lines with the file exporter
+	SampleRatio    float64 `json:"sample_ratio"` // share of new traces recorded, incoming sampled traces are always recorded
+}
+
 // LoadConfigs is a pseudo factory pattern to load requested configurations
 // a bit overkill for this case, but could be useful in a more complex scenario
-func LoadConfigs(requestedServices []string) Configs {
+func (l *Loader) LoadConfigs(requestedServices []string) Configs {
 	configs := make(map[string]interface{})
-	serverName := getEnv("SERVER_NAME", "unknown_server")
+	serverName := l.getString("SERVER_NAME", "unknown_server")
 	for _, service := range requestedServices {
 		switch service {
 		case LoggerConfigKey:
-			logConfig := getLoggerConfig()
+			logConfig := l.getLoggerConfig()
 			logConfig.ServerName = serverName
 			configs[LoggerConfigKey] = logConfig
 		case MetricsConfigKey:
@@ -56,17 +91,21 @@ func LoadConfigs(requestedServices []string) Configs {
 			}
 			configs[MetricsConfigKey] = metricsConfig
 		case CalculatorConfigKey:
-			calculatorConfig := getDefaultCalculatorConfig()
+			calculatorConfig := l.getDefaultCalculatorConfig()
 			configs[CalculatorConfigKey] = calculatorConfig
+		case TracingConfigKey:
+			tracingConfig := l.getTracingConfig()
+			tracingConfig.ServerName = serverName
+			configs[TracingConfigKey] = tracingConfig
 		}
 	}
 	return configs
 }
 
-func getLoggerConfig() LoggerConfig {
-	timeFormat := getEnv("LOG_TIME_FORMAT", "2006-01-02 15:04:05")
-	level := getEnv("LOG_LEVEL", logrus.InfoLevel.String())
-	format := getEnv("LOG_FORMAT", "text")
+func (l *Loader) getLoggerConfig() LoggerConfig {
+	timeFormat := l.getString("LOG_TIME_FORMAT", "2006-01-02 15:04:05")
+	level := l.getString("LOG_LEVEL", logrus.InfoLevel.String())
+	format := l.getString("LOG_FORMAT", "text")
 
 	return LoggerConfig{
 		TimeFormat: timeFormat,
@@ -74,38 +113,84 @@ func getLoggerConfig() LoggerConfig {
 		Format:     format,
 	}
 }
-func getDefaultCalculatorConfig() CalculatorConfig {
-	version := getEnv("CALCULATOR_VERSION", "1.0.0")
-	port := getEnv("CALCULATOR_PORT", "8080")
-	storageType := getEnv("CALCULATOR_STORAGE_TYPE", "memory")
-	storageFilePath := getEnv("CALCULATOR_STORAGE_PATH", "./storage.txt")
-	readTimeout := time.Second * time.Duration(getEnvAsInt("CALCULATOR_READ_TIMEOUT", 5))
-	writeTimeout := time.Second * time.Duration(getEnvAsInt("CALCULATOR_WRITE_TIMEOUT", 10))
-	idleTimeout := time.Second * time.Duration(getEnvAsInt("CALCULATOR_IDLE_TIMEOUT", 120))
-
-	return CalculatorConfig{
-		Version:         version,
-		StorageType:     storageType,
-		StorageFilePath: storageFilePath,
-		Port:            port,
-		ReadTimeout:     readTimeout,
-		WriteTimeout:    writeTimeout,
-		IdleTimeout:     idleTimeout,
+func (l *Loader) getTracingConfig() TracingConfig {
+	return TracingConfig{
+		Exporter:     l.getString("TRACING_EXPORTER", "none"),
+		OTLPEndpoint: l.getString("TRACING_OTLP_ENDPOINT", ""),
+		OTLPProtocol: l.getString("TRACING_OTLP_PROTOCOL", "grpc"),
+		OTLPInsecure: l.getBool("TRACING_OTLP_INSECURE", false),
+		FilePath:     l.getString("TRACING_FILE_PATH", "./traces.jsonl"),
+		SampleRatio:  l.getFloat("TRACING_SAMPLE_RATIO", 1),
 	}
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+func (l *Loader) getDefaultCalculatorConfig() CalculatorConfig {
+	version := l.getString("CALCULATOR_VERSION", "1.0.0")
+	port := l.getString("CALCULATOR_PORT", "8080")
+	grpcPort := l.getString("CALCULATOR_GRPC_PORT", "50051")
+	storageType := l.getString("CALCULATOR_STORAGE_TYPE", "memory")
+	// each backend has a default of its own, a database must not take the place of the file backend's log
+	defaultStoragePath := "./storage.txt"
+	if storageType == "sqlite" {
+		defaultStoragePath = "./storage.db"
 	}
-	return defaultValue
-}
+	storageFilePath := l.getString("CALCULATOR_STORAGE_PATH", defaultStoragePath)
+	storageSync := l.getString("CALCULATOR_STORAGE_SYNC", "interval")
+	storageSyncInterval := time.Millisecond * time.Duration(l.getInt("CALCULATOR_STORAGE_SYNC_INTERVAL_MS", 1000))
+	readTimeout := time.Second * time.Duration(l.getInt("CALCULATOR_READ_TIMEOUT", 5))
+	writeTimeout := time.Second * time.Duration(l.getInt("CALCULATOR_WRITE_TIMEOUT", 10))
+	idleTimeout := time.Second * time.Duration(l.getInt("CALCULATOR_IDLE_TIMEOUT", 120))
+	precision := l.getInt("CALCULATOR_PRECISION", 0)
+	batchWorkers := l.getInt("CALCULATOR_BATCH_WORKERS", runtime.NumCPU())
+	batchMaxItems := l.getInt("CALCULATOR_BATCH_MAX_ITEMS", 1000)
+	maxBodyBytes := l.getInt("CALCULATOR_MAX_BODY_BYTES", 1<<20)
+	trustedProxies := l.getList("CALCULATOR_TRUSTED_PROXIES", nil)
+	rateLimit := l.getFloat("CALCULATOR_RATE_LIMIT", 0)
+	rateBurst := l.getInt("CALCULATOR_RATE_BURST", 20)
+	streamBuffer := l.getInt("CALCULATOR_STREAM_BUFFER", 256)
+	sessionTTL := time.Second * time.Duration(l.getInt("CALCULATOR_SESSION_TTL", 1800))
+	sessionMax := l.getInt("CALCULATOR_SESSION_MAX", 10000)
+	sessionMaxPerClient := l.getInt("CALCULATOR_SESSION_MAX_PER_CLIENT", 10)
+	sessionHistory := l.getInt("CALCULATOR_SESSION_HISTORY", 100)
+	functionMax := l.getInt("CALCULATOR_FUNCTION_MAX", 100)
+	functionMaxDepth := l.getInt("CALCULATOR_FUNCTION_MAX_DEPTH", 32)
+	functionMaxSteps := l.getInt("CALCULATOR_FUNCTION_MAX_STEPS", 100000)
+	healthCacheTTL := time.Millisecond * time.Duration(l.getInt("CALCULATOR_HEALTH_CACHE_MS", 2000))
+	healthTimeout := time.Millisecond * time.Duration(l.getInt("CALCULATOR_HEALTH_TIMEOUT_MS", 1000))
+	healthMinFreeMB := l.getInt("CALCULATOR_HEALTH_MIN_FREE_MB", 100)
+	shutdownTimeout := time.Second * time.Duration(l.getInt("CALCULATOR_SHUTDOWN_TIMEOUT", 30))
+	shutdownDelay := time.Millisecond * time.Duration(l.getInt("CALCULATOR_SHUTDOWN_DELAY_MS", 0))
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
+	return CalculatorConfig{
+		Version:             version,
+		StorageType:         storageType,
+		StorageFilePath:     storageFilePath,
+		StorageSync:         storageSync,
+		StorageSyncInterval: storageSyncInterval,
+		Port:                port,
+		GRPCPort:            grpcPort,
+		ReadTimeout:         readTimeout,
+		WriteTimeout:        writeTimeout,
+		IdleTimeout:         idleTimeout,
+		Precision:           precision,
+		BatchWorkers:        batchWorkers,
+		BatchMaxItems:       batchMaxItems,
+		MaxBodyBytes:        maxBodyBytes,
+		TrustedProxies:      trustedProxies,
+		RateLimit:           rateLimit,
+		RateBurst:           rateBurst,
+		StreamBuffer:        streamBuffer,
+		SessionTTL:          sessionTTL,
+		SessionMax:          sessionMax,
+		SessionMaxPerClient: sessionMaxPerClient,
+		SessionHistory:      sessionHistory,
+		FunctionMax:         functionMax,
+		FunctionMaxDepth:    functionMaxDepth,
+		FunctionMaxSteps:    functionMaxSteps,
+		HealthCacheTTL:      healthCacheTTL,
+		HealthTimeout:       healthTimeout,
+		HealthMinFreeMB:     healthMinFreeMB,
+		ShutdownTimeout:     shutdownTimeout,
+		ShutdownDelay:       shutdownDelay,
 	}
-	return defaultValue
 }
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Every setting has a single name in all layers, derived from its environment variable:
+// CALCULATOR_STORAGE_TYPE is calculator.storage_type in the file and --calculator.storage_type on the command line.
+// Layers override each other in this order: default < file < environment < flag.
+
+// Source tells which layer a value came from
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// ConfigFileEnv names the config file when --config is not given
+const ConfigFileEnv = "CONFIG_FILE"
+
+// sections are the environment variable prefixes that become a section of the config file
+var sections = []string{"CALCULATOR", "LOG", "TRACING"}
+
+// Setting is a value looked up while loading, with where it came from
+type Setting struct {
+	Key    string // calculator.port
+	Env    string // CALCULATOR_PORT
+	Value  string
+	Source Source
+}
+
+// Loader resolves settings through the layers and remembers every lookup for PrintConfig
+type Loader struct {
+	// PrintConfig is set by --print-config: print the effective configuration and exit
+	PrintConfig bool
+
+	file     string
+	fileKeys map[string]string
+	flags    map[string]string
+	settings []Setting
+	problems []Problem // values that didn't parse, reported by Validate
+}
+
+// NewLoader parses the command line and reads the config file given with --config or CONFIG_FILE.
+// Flags are --key=value or --key value, a bare --key sets it to true. Only --key starts a new flag,
+// so negative values like --calculator.read_timeout -1 are read as values.
+func NewLoader(args []string) (*Loader, error) {
+	l := &Loader{flags: make(map[string]string)}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			return nil, fmt.Errorf("unexpected argument '%s', settings are given as --key=value", arg)
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !hasValue {
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+				i++
+				value = args[i]
+			} else {
+				value = "true"
+			}
+		}
+		if name == "print-config" {
+			printConfig, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("--print-config expects true or false, got '%s'", value)
+			}
+			l.PrintConfig = printConfig
+			continue
+		}
+		l.flags[name] = value
+	}
+
+	l.file = l.flags["config"]
+	delete(l.flags, "config")
+	if l.file == "" {
+		l.file = os.Getenv(ConfigFileEnv)
+	}
+	if l.file != "" {
+		keys, err := readFile(l.file)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %w", l.file, err)
+		}
+		l.fileKeys = keys
+	}
+	return l, nil
+}
+
+// lookup returns the value of the setting named by its environment variable, defaultValue when no layer sets it
+func (l *Loader) lookup(env, defaultValue string) (string, Source) {
+	key := keyOf(env)
+	if value, ok := l.flags[key]; ok {
+		return value, SourceFlag
+	}
+	if value := os.Getenv(env); value != "" {
+		return value, SourceEnv
+	}
+	if value, ok := l.fileKeys[key]; ok {
+		return value, SourceFile
+	}
+	return defaultValue, SourceDefault
+}
+
+// record keeps the value that ended up in the configuration
+func (l *Loader) record(env, value string, source Source) {
+	l.settings = append(l.settings, Setting{Key: keyOf(env), Env: env, Value: value, Source: source})
+}
+
+// invalid keeps a value that didn't parse, the default stands in for it until Validate reports it
+func (l *Loader) invalid(env, message string) {
+	l.problems = append(l.problems, Problem{Key: keyOf(env), Message: message})
+}
+
+func (l *Loader) getString(env, defaultValue string) string {
+	value, source := l.lookup(env, defaultValue)
+	l.record(env, value, source)
+	return value
+}
+
+func (l *Loader) getInt(env string, defaultValue int) int {
+	if value, source := l.lookup(env, ""); source != SourceDefault {
+		l.record(env, value, source)
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			l.invalid(env, "is not an integer")
+			return defaultValue
+		}
+		return intValue
+	}
+	l.record(env, strconv.Itoa(defaultValue), SourceDefault)
+	return defaultValue
+}
+
+func (l *Loader) getBool(env string, defaultValue bool) bool {
+	if value, source := l.lookup(env, ""); source != SourceDefault {
+		l.record(env, value, source)
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			l.invalid(env, "is not a boolean")
+			return defaultValue
+		}
+		return boolValue
+	}
+	l.record(env, strconv.FormatBool(defaultValue), SourceDefault)
+	return defaultValue
+}
+
+// getList reads a comma separated setting, blank items are dropped
+func (l *Loader) getList(env string, defaultValue []string) []string {
+	value := l.getString(env, strings.Join(defaultValue, ","))
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (l *Loader) getFloat(env string, defaultValue float64) float64 {
+	if value, source := l.lookup(env, ""); source != SourceDefault {
+		l.record(env, value, source)
+		floatValue, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			l.invalid(env, "is not a number")
+			return defaultValue
+		}
+		return floatValue
+	}
+	l.record(env, strconv.FormatFloat(defaultValue, 'f', -1, 64), SourceDefault)
+	return defaultValue
+}
+
+// Settings returns every setting looked up so far, in lookup order
+func (l *Loader) Settings() []Setting {
+	return l.settings
+}
+
+// Print writes the effective configuration with the origin of every value
+func (l *Loader) Print(w io.Writer) error {
+	out := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "KEY\tVALUE\tSOURCE")
+	seen := make(map[string]bool)
+	for _, setting := range l.settings {
+		if seen[setting.Key] {
+			continue // a setting read for several configs is listed once
+		}
+		seen[setting.Key] = true
+		fmt.Fprintf(out, "%s\t%s\t%s\n", setting.Key, strconv.Quote(setting.Value), l.origin(setting))
+	}
+	return out.Flush()
+}
+
+func (l *Loader) origin(setting Setting) string {
+	switch setting.Source {
+	case SourceFlag:
+		return "flag --" + setting.Key
+	case SourceEnv:
+		return "env " + setting.Env
+	case SourceFile:
+		return "file " + l.file
+	}
+	return string(setting.Source)
+}
+
+// keyOf turns CALCULATOR_STORAGE_TYPE into calculator.storage_type
+func keyOf(env string) string {
+	for _, section := range sections {
+		if rest, ok := strings.CutPrefix(env, section+"_"); ok {
+			return strings.ToLower(section) + "." + strings.ToLower(rest)
+		}
+	}
+	return strings.ToLower(env)
+}
+
+// readFile parses the file by its extension and flattens sections into dotted keys
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	case ".json":
+		err = json.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("unsupported extension '%s', use .yaml, .yml, .toml or .json", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]string)
+	if err := flatten("", tree, keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func flatten(prefix string, tree map[string]interface{}, keys map[string]string) error {
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value := tree[name].(type) {
+		case map[string]interface{}:
+			if err := flatten(key, value, keys); err != nil {
+				return err
+			}
+		case []interface{}:
+			return fmt.Errorf("%s: lists are not supported", key)
+		case nil:
+			// an empty entry leaves the setting to the lower layers
+		case float64: // JSON numbers, 1e3 is port 1000
+			keys[key] = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			keys[key] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
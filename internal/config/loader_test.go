@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// setting returns the last lookup of the key, the one that ended up in the configuration
+func setting(t *testing.T, l *Loader, key string) Setting {
+	t.Helper()
+	settings := l.Settings()
+	for i := len(settings) - 1; i >= 0; i-- {
+		if settings[i].Key == key {
+			return settings[i]
+		}
+	}
+	t.Fatalf("%s was not looked up", key)
+	return Setting{}
+}
+
+func TestLoaderPrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string // calculator.port in the config file, empty for no file
+		env        string
+		flag       string
+		want       string
+		wantSource Source
+	}{
+		{name: "default", want: "8080", wantSource: SourceDefault},
+		{name: "file", file: "7001", want: "7001", wantSource: SourceFile},
+		{name: "env over file", file: "7001", env: "7002", want: "7002", wantSource: SourceEnv},
+		{name: "flag over env", file: "7001", env: "7002", flag: "7003", want: "7003", wantSource: SourceFlag},
+		{name: "flag over file", file: "7001", flag: "7003", want: "7003", wantSource: SourceFlag},
+		{name: "flag over default", flag: "7003", want: "7003", wantSource: SourceFlag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ConfigFileEnv, "")
+			t.Setenv("CALCULATOR_PORT", tt.env) // empty counts as unset
+			var args []string
+			if tt.file != "" {
+				args = append(args, "--config", writeConfigFile(t, "config.yaml", "calculator:\n  port: "+tt.file+"\n"))
+			}
+			if tt.flag != "" {
+				args = append(args, "--calculator.port="+tt.flag)
+			}
+
+			loader, err := NewLoader(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			configs := loader.LoadConfigs([]string{CalculatorConfigKey})
+			if got := configs[CalculatorConfigKey].(CalculatorConfig).Port; got != tt.want {
+				t.Errorf("port = %s, want %s", got, tt.want)
+			}
+			if got := setting(t, loader, "calculator.port"); got.Source != tt.wantSource || got.Env != "CALCULATOR_PORT" {
+				t.Errorf("setting = %+v, want source %s", got, tt.wantSource)
+			}
+		})
+	}
+}
+
+// The same settings in every format, sections and keys are case insensitive
+func TestLoaderFileFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"config.yaml", "calculator:\n  port: 9090\n  storage_type: file\nlog:\n  level: debug\ntracing:\n  sample_ratio: 0.25\nserver_name: calc-1\n"},
+		{"config.yml", "Calculator:\n  PORT: \"9090\"\n  storage_type: file\nlog: {level: debug}\ntracing: {sample_ratio: 0.25}\nserver_name: calc-1\n"},
+		{"config.toml", "server_name = \"calc-1\"\n[calculator]\nport = 9090\nstorage_type = \"file\"\n[log]\nlevel = \"debug\"\n[tracing]\nsample_ratio = 0.25\n"},
+		{"config.json", `{"server_name": "calc-1", "calculator": {"port": 9090, "storage_type": "file"}, "log": {"level": "debug"}, "tracing": {"sample_ratio": 0.25}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ConfigFileEnv, writeConfigFile(t, tt.name, tt.content))
+			loader, err := NewLoader(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			configs := loader.LoadConfigs([]string{LoggerConfigKey, CalculatorConfigKey, TracingConfigKey})
+
+			calculator := configs[CalculatorConfigKey].(CalculatorConfig)
+			logger := configs[LoggerConfigKey].(LoggerConfig)
+			tracing := configs[TracingConfigKey].(TracingConfig)
+			if calculator.Port != "9090" || calculator.StorageType != "file" || logger.Level != "debug" ||
+				logger.ServerName != "calc-1" || tracing.SampleRatio != 0.25 {
+				t.Errorf("loaded port %s, storage_type %s, level %s, server_name %s, sample_ratio %v",
+					calculator.Port, calculator.StorageType, logger.Level, logger.ServerName, tracing.SampleRatio)
+			}
+			if err := loader.Validate(configs); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"config.ini", "[calculator]\nport = 9090\n"},
+		{"config.yaml", "calculator: [port, 9090]\n"},
+		{"config.json", `{"calculator": {"port": 9090}`},
+		{"config.toml", "[calculator\nport = 9090\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewLoader([]string{"--config", writeConfigFile(t, tt.name, tt.content)}); err == nil {
+				t.Errorf("%s was loaded without an error", tt.content)
+			}
+		})
+	}
+	if _, err := NewLoader([]string{"--config=" + filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("a missing config file was loaded without an error")
+	}
+}
+
+func TestNewLoaderArgs(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		wantFlags       map[string]string
+		wantPrintConfig bool
+		wantErr         bool
+	}{
+		{name: "key=value", args: []string{"--calculator.port=9090"}, wantFlags: map[string]string{"calculator.port": "9090"}},
+		{name: "key value", args: []string{"--calculator.port", "9090"}, wantFlags: map[string]string{"calculator.port": "9090"}},
+		{name: "negative value", args: []string{"--calculator.read_timeout", "-1"}, wantFlags: map[string]string{"calculator.read_timeout": "-1"}},
+		{name: "bare key is true", args: []string{"--tracing.otlp_insecure", "--calculator.port=1"},
+			wantFlags: map[string]string{"tracing.otlp_insecure": "true", "calculator.port": "1"}},
+		{name: "value with equals", args: []string{"--log.time_format=a=b"}, wantFlags: map[string]string{"log.time_format": "a=b"}},
+		{name: "print-config", args: []string{"--print-config"}, wantFlags: map[string]string{}, wantPrintConfig: true},
+		{name: "print-config=false", args: []string{"--print-config=false"}, wantFlags: map[string]string{}},
+		{name: "print-config before a flag", args: []string{"--print-config", "--calculator.port=1"},
+			wantFlags: map[string]string{"calculator.port": "1"}, wantPrintConfig: true},
+		{name: "invalid print-config", args: []string{"--print-config=maybe"}, wantErr: true},
+		{name: "single dash", args: []string{"-calculator.port=9090"}, wantErr: true},
+		{name: "stray argument", args: []string{"--calculator.port=9090", "9091"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ConfigFileEnv, "")
+			loader, err := NewLoader(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("NewLoader(%q) succeeded, want an error", tt.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(loader.flags, tt.wantFlags) || loader.PrintConfig != tt.wantPrintConfig {
+				t.Errorf("NewLoader(%q) = flags %v, print-config %v, want %v, %v",
+					tt.args, loader.flags, loader.PrintConfig, tt.wantFlags, tt.wantPrintConfig)
+			}
+		})
+	}
+}
+
+// Regression: the SQLite database was written to the file backend's ./storage.txt
+func TestStoragePathDefault(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: nil, want: "./storage.txt"},
+		{args: []string{"--calculator.storage_type=file"}, want: "./storage.txt"},
+		{args: []string{"--calculator.storage_type=sqlite"}, want: "./storage.db"},
+		{args: []string{"--calculator.storage_type=sqlite", "--calculator.storage_path=/data/calc.sqlite"}, want: "/data/calc.sqlite"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.args), func(t *testing.T) {
+			t.Setenv(ConfigFileEnv, "")
+			loader, err := NewLoader(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			calculator := loader.LoadConfigs([]string{CalculatorConfigKey})[CalculatorConfigKey].(CalculatorConfig)
+			if calculator.StorageFilePath != tt.want {
+				t.Errorf("storage path = %s, want %s", calculator.StorageFilePath, tt.want)
+			}
+		})
+	}
+}
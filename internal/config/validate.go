@@ -0,0 +1,235 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Problem is a setting that can't be used, Key is its name in the config file
+type Problem struct {
+	Key     string
+	Message string
+}
+
+// Check is a validation rule that needs more than the config package knows, like the registered storage backends
+type Check func(Configs) []Problem
+
+// ValidationError lists every problem of the configuration so they can all be fixed in one go
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d problem(s) in the configuration:", len(e.Problems))
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(problem)
+	}
+	return b.String()
+}
+
+// Validate checks the configurations returned by LoadConfigs: values that didn't parse, keys of the file
+// or flags that no setting reads, the rules of every config and the extra checks.
+// It returns a *ValidationError, or nil when the configuration can be used.
+func (l *Loader) Validate(configs Configs, checks ...Check) error {
+	problems := append([]Problem(nil), l.problems...)
+	problems = append(problems, l.unknownKeys()...)
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if validator, ok := configs[name].(interface{ Validate() []Problem }); ok {
+			problems = append(problems, validator.Validate()...)
+		}
+	}
+	for _, check := range checks {
+		problems = append(problems, check(configs)...)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	// one problem per setting: a value that didn't parse is replaced by its default, the rules checked on it
+	// afterwards would only add noise
+	reported := make(map[string]bool)
+	lines := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		if reported[problem.Key] {
+			continue
+		}
+		reported[problem.Key] = true
+		lines = append(lines, l.describe(problem))
+	}
+	return &ValidationError{Problems: lines}
+}
+
+// describe puts the value and its origin next to the problem, the setting isn't always where the user looks first
+func (l *Loader) describe(problem Problem) string {
+	for _, setting := range l.settings {
+		if setting.Key == problem.Key {
+			return fmt.Sprintf("%s = %s (%s): %s", setting.Key, strconv.Quote(setting.Value), l.origin(setting), problem.Message)
+		}
+	}
+	return fmt.Sprintf("%s: %s", problem.Key, problem.Message)
+}
+
+// unknownKeys reports keys of the file and flags no setting was looked up for, most likely typos
+func (l *Loader) unknownKeys() []Problem {
+	known := make(map[string]bool, len(l.settings))
+	for _, setting := range l.settings {
+		known[setting.Key] = true
+	}
+	var problems []Problem
+	for _, layer := range []struct {
+		keys   map[string]string
+		origin string
+	}{
+		{l.fileKeys, "file " + l.file},
+		{l.flags, "flag"},
+	} {
+		var unknown []string
+		for key := range layer.keys {
+			if !known[key] {
+				unknown = append(unknown, key)
+			}
+		}
+		sort.Strings(unknown)
+		for _, key := range unknown {
+			problems = append(problems, Problem{Key: key, Message: "unknown setting in " + layer.origin})
+		}
+	}
+	return problems
+}
+
+// problems collects the broken rules of a config, named by the environment variable of the setting
+type problems []Problem
+
+func (p *problems) check(ok bool, env, format string, args ...interface{}) {
+	if !ok {
+		*p = append(*p, Problem{Key: keyOf(env), Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+func (p *problems) port(env, value string) {
+	port, err := strconv.Atoi(value)
+	p.check(err == nil && port >= 1 && port <= 65535, env, "must be a port number between 1 and 65535")
+}
+
+func (p *problems) oneOf(env, value string, allowed ...string) {
+	for _, name := range allowed {
+		if value == name {
+			return
+		}
+	}
+	p.check(false, env, "must be one of %s", strings.Join(allowed, ", "))
+}
+
+// Validate checks the ranges and enums of the calculator settings. The storage type and path depend on
+// the registered backends and are checked by the calculator package.
+func (c CalculatorConfig) Validate() []Problem {
+	var p problems
+	p.check(c.Version != "", "CALCULATOR_VERSION", "must not be empty")
+	p.port("CALCULATOR_PORT", c.Port)
+	p.port("CALCULATOR_GRPC_PORT", c.GRPCPort)
+	p.check(c.Port != c.GRPCPort, "CALCULATOR_GRPC_PORT", "must differ from calculator.port")
+	p.oneOf("CALCULATOR_STORAGE_SYNC", c.StorageSync, "always", "interval", "none")
+	p.check(c.StorageSyncInterval > 0 || c.StorageSync != "interval", "CALCULATOR_STORAGE_SYNC_INTERVAL_MS", "must be positive with the interval sync")
+	p.check(c.ReadTimeout >= 0, "CALCULATOR_READ_TIMEOUT", "must not be negative")
+	p.check(c.WriteTimeout >= 0, "CALCULATOR_WRITE_TIMEOUT", "must not be negative")
+	p.check(c.IdleTimeout >= 0, "CALCULATOR_IDLE_TIMEOUT", "must not be negative")
+	p.check(c.Precision >= 0, "CALCULATOR_PRECISION", "must not be negative")
+	p.check(c.BatchWorkers > 0, "CALCULATOR_BATCH_WORKERS", "must be positive")
+	p.check(c.BatchMaxItems > 0, "CALCULATOR_BATCH_MAX_ITEMS", "must be positive")
+	p.check(c.MaxBodyBytes > 0, "CALCULATOR_MAX_BODY_BYTES", "must be positive")
+	for _, proxy := range c.TrustedProxies {
+		p.check(validProxy(proxy), "CALCULATOR_TRUSTED_PROXIES", "'%s' is neither an IP address nor a CIDR", proxy)
+	}
+	p.check(c.RateLimit >= 0, "CALCULATOR_RATE_LIMIT", "must not be negative")
+	p.check(c.RateBurst > 0, "CALCULATOR_RATE_BURST", "must be positive")
+	p.check(c.StreamBuffer > 0, "CALCULATOR_STREAM_BUFFER", "must be positive")
+	p.check(c.SessionTTL > 0, "CALCULATOR_SESSION_TTL", "must be positive")
+	p.check(c.SessionMax > 0, "CALCULATOR_SESSION_MAX", "must be positive")
+	p.check(c.SessionMaxPerClient > 0, "CALCULATOR_SESSION_MAX_PER_CLIENT", "must be positive")
+	p.check(c.SessionHistory >= 0, "CALCULATOR_SESSION_HISTORY", "must not be negative")
+	p.check(c.FunctionMax >= 0, "CALCULATOR_FUNCTION_MAX", "must not be negative")
+	p.check(c.FunctionMaxDepth > 0, "CALCULATOR_FUNCTION_MAX_DEPTH", "must be positive")
+	p.check(c.FunctionMaxSteps > 0, "CALCULATOR_FUNCTION_MAX_STEPS", "must be positive")
+	p.check(c.HealthCacheTTL >= 0, "CALCULATOR_HEALTH_CACHE_MS", "must not be negative")
+	p.check(c.HealthTimeout > 0, "CALCULATOR_HEALTH_TIMEOUT_MS", "must be positive")
+	p.check(c.HealthMinFreeMB >= 0, "CALCULATOR_HEALTH_MIN_FREE_MB", "must not be negative")
+	p.check(c.ShutdownTimeout > 0, "CALCULATOR_SHUTDOWN_TIMEOUT", "must be positive")
+	p.check(c.ShutdownDelay >= 0, "CALCULATOR_SHUTDOWN_DELAY_MS", "must not be negative")
+	p.check(c.ShutdownDelay < c.ShutdownTimeout || c.ShutdownTimeout <= 0, "CALCULATOR_SHUTDOWN_DELAY_MS",
+		"must be shorter than calculator.shutdown_timeout, nothing would be left to drain the requests")
+	return p
+}
+
+// validProxy accepts what gin's SetTrustedProxies does
+func validProxy(proxy string) bool {
+	if strings.Contains(proxy, "/") {
+		_, _, err := net.ParseCIDR(proxy)
+		return err == nil
+	}
+	return net.ParseIP(proxy) != nil
+}
+
+func (c LoggerConfig) Validate() []Problem {
+	var p problems
+	_, err := logrus.ParseLevel(c.Level)
+	p.check(err == nil, "LOG_LEVEL", "must be one of panic, fatal, error, warn, info, debug, trace")
+	p.oneOf("LOG_FORMAT", strings.ToLower(c.Format), "text", "json")
+	p.check(c.TimeFormat != "", "LOG_TIME_FORMAT", "must not be empty")
+	return p
+}
+
+func (c MetricsConfig) Validate() []Problem {
+	var p problems
+	p.check(c.ServerName != "", "SERVER_NAME", "must not be empty, it labels every metric")
+	return p
+}
+
+func (c TracingConfig) Validate() []Problem {
+	var p problems
+	p.oneOf("TRACING_EXPORTER", strings.ToLower(c.Exporter), "none", "otlp", "stdout", "file")
+	p.oneOf("TRACING_OTLP_PROTOCOL", strings.ToLower(c.OTLPProtocol), "grpc", "http")
+	p.check(c.SampleRatio >= 0 && c.SampleRatio <= 1, "TRACING_SAMPLE_RATIO", "must be between 0 and 1")
+	if strings.EqualFold(c.Exporter, "file") {
+		err := Writable(c.FilePath)
+		p.check(err == nil, "TRACING_FILE_PATH", "%v", err)
+	}
+	return p
+}
+
+// Writable tells whether the service can create or append to the file at path, without changing it
+func Writable(path string) error {
+	if path == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("is a directory")
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return fmt.Errorf("is not writable: %w", err)
+		}
+		return file.Close()
+	}
+	dir := filepath.Dir(path)
+	probe, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var allConfigs = []string{LoggerConfigKey, MetricsConfigKey, CalculatorConfigKey, TracingConfigKey}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string // problems in report order, none when the configuration is valid
+	}{
+		{name: "defaults"},
+		{name: "valid flags", args: []string{"--calculator.port=1", "--calculator.grpc_port=65535", "--log.format=JSON", "--tracing.sample_ratio=0"}},
+		{name: "port out of range", args: []string{"--calculator.port", "70000"},
+			want: []string{`calculator.port = "70000" (flag --calculator.port): must be a port number between 1 and 65535`}},
+		{name: "port is not a number", args: []string{"--calculator.grpc_port=http"},
+			want: []string{`calculator.grpc_port = "http" (flag --calculator.grpc_port): must be a port number between 1 and 65535`}},
+		{name: "same ports", args: []string{"--calculator.grpc_port=8080"},
+			want: []string{`calculator.grpc_port = "8080" (flag --calculator.grpc_port): must differ from calculator.port`}},
+		{name: "negative timeout", args: []string{"--calculator.read_timeout", "-1"},
+			want: []string{`calculator.read_timeout = "-1" (flag --calculator.read_timeout): must not be negative`}},
+		{name: "unknown enum", args: []string{"--calculator.storage_sync=sometimes", "--log.format=xml"},
+			want: []string{
+				`calculator.storage_sync = "sometimes" (flag --calculator.storage_sync): must be one of always, interval, none`,
+				`log.format = "xml" (flag --log.format): must be one of text, json`,
+			}},
+		{name: "interval sync without an interval", args: []string{"--calculator.storage_sync_interval_ms=0"},
+			want: []string{`calculator.storage_sync_interval_ms = "0" (flag --calculator.storage_sync_interval_ms): must be positive with the interval sync`}},
+		{name: "no interval needed", args: []string{"--calculator.storage_sync=always", "--calculator.storage_sync_interval_ms=0"}},
+		{name: "sample ratio", args: []string{"--tracing.sample_ratio=1.5"},
+			want: []string{`tracing.sample_ratio = "1.5" (flag --tracing.sample_ratio): must be between 0 and 1`}},
+		{name: "shutdown delay longer than the timeout", args: []string{"--calculator.shutdown_timeout=1", "--calculator.shutdown_delay_ms=1000"},
+			want: []string{`calculator.shutdown_delay_ms = "1000" (flag --calculator.shutdown_delay_ms): must be shorter than calculator.shutdown_timeout, nothing would be left to drain the requests`}},
+		{name: "trusted proxies", args: []string{"--calculator.trusted_proxies= 10.0.0.1, 10.1.0.0/16,,proxy.local"},
+			want: []string{`calculator.trusted_proxies = " 10.0.0.1, 10.1.0.0/16,,proxy.local" (flag --calculator.trusted_proxies): 'proxy.local' is neither an IP address nor a CIDR`}},
+		{name: "rate limit", args: []string{"--calculator.rate_limit=-1", "--calculator.rate_burst=0"},
+			want: []string{
+				`calculator.rate_limit = "-1" (flag --calculator.rate_limit): must not be negative`,
+				`calculator.rate_burst = "0" (flag --calculator.rate_burst): must be positive`,
+			}},
+		{name: "one problem per key", args: []string{"--calculator.batch_workers=many"},
+			want: []string{`calculator.batch_workers = "many" (flag --calculator.batch_workers): is not an integer`}},
+		{name: "parse errors", args: []string{"--tracing.otlp_insecure=sure", "--tracing.sample_ratio=half"},
+			want: []string{
+				`tracing.otlp_insecure = "sure" (flag --tracing.otlp_insecure): is not a boolean`,
+				`tracing.sample_ratio = "half" (flag --tracing.sample_ratio): is not a number`,
+			}},
+		{name: "unknown flag", args: []string{"--calculator.prot=9090"},
+			want: []string{`calculator.prot: unknown setting in flag`}},
+		{name: "every problem at once", args: []string{"--calculator.port=0", "--log.level=loud", "--calculator.session_ttl=0", "--typo"},
+			want: []string{
+				`typo: unknown setting in flag`,
+				`calculator.port = "0" (flag --calculator.port): must be a port number between 1 and 65535`,
+				`calculator.session_ttl = "0" (flag --calculator.session_ttl): must be positive`,
+				`log.level = "loud" (flag --log.level): must be one of panic, fatal, error, warn, info, debug, trace`,
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ConfigFileEnv, "")
+			loader, err := NewLoader(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = loader.Validate(loader.LoadConfigs(allConfigs))
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate error = %v, want a ValidationError", err)
+			}
+			if !reflect.DeepEqual(validationErr.Problems, tt.want) {
+				t.Errorf("problems:\n%q\nwant:\n%q", validationErr.Problems, tt.want)
+			}
+		})
+	}
+}
+
+// Problems name the layer the value came from, a key unknown to the file names the file
+func TestValidateOrigins(t *testing.T) {
+	file := writeConfigFile(t, "config.yaml", "calculator:\n  precision: -3\n  storage_tpye: file\n")
+	t.Setenv(ConfigFileEnv, file)
+	t.Setenv("CALCULATOR_BATCH_MAX_ITEMS", "0")
+	loader, err := NewLoader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(configs Configs) []Problem {
+		return []Problem{{Key: "calculator.storage_type", Message: "no backend registered"}}
+	}
+	err = loader.Validate(loader.LoadConfigs(allConfigs), check)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate error = %v, want a ValidationError", err)
+	}
+	want := []string{
+		`calculator.storage_tpye: unknown setting in file ` + file,
+		`calculator.precision = "-3" (file ` + file + `): must not be negative`,
+		`calculator.batch_max_items = "0" (env CALCULATOR_BATCH_MAX_ITEMS): must be positive`,
+		`calculator.storage_type = "memory" (default): no backend registered`,
+	}
+	if !reflect.DeepEqual(validationErr.Problems, want) {
+		t.Errorf("problems:\n%q\nwant:\n%q", validationErr.Problems, want)
+	}
+}
+
+func TestWritable(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.jsonl")
+	if err := os.WriteFile(existing, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	readOnly := filepath.Join(dir, "read-only.jsonl")
+	if err := os.WriteFile(readOnly, nil, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "new file", path: filepath.Join(dir, "new.jsonl")},
+		{name: "existing file", path: existing},
+		{name: "empty", path: "", wantErr: true},
+		{name: "directory", path: dir, wantErr: true},
+		{name: "missing directory", path: filepath.Join(dir, "missing", "traces.jsonl"), wantErr: true},
+		{name: "read-only file", path: readOnly, wantErr: os.Geteuid() != 0}, // root writes anyway
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Writable(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Writable(%q) = %v, want error %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+
+	// the check leaves nothing behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("directory has %d entries after the checks, want the 2 files written by the test", len(entries))
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "{}\n" {
+		t.Errorf("existing file was changed to %q", data)
+	}
+}
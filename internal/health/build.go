@@ -0,0 +1,58 @@
+package health
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Commit and BuildTime are set by the linker, see the build target of the Makefile:
+//
+//	go build -ldflags "-X CalculatorWebService/internal/health.Commit=$(git rev-parse --short HEAD)"
+//
+// Without them the VCS information stamped by the go command is used, when there is any.
+var (
+	Commit    string
+	BuildTime string
+)
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	Modified  bool   `json:"modified,omitempty"` // built from a work tree with uncommitted changes
+	GoVersion string `json:"go_version"`
+}
+
+// Build returns the build information of the binary, version is the configured service version
+func Build(version string) BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+	if stamped, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range stamped.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value // commit time, the closest we have
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
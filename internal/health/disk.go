@@ -0,0 +1,39 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DiskSpace checks the file system holding dir has at least minFree bytes available to the service.
+// On platforms where free space can't be read the check passes with a note.
+func DiskSpace(dir string, minFree uint64) Check {
+	return func(ctx context.Context) (string, error) {
+		free, err := freeSpace(dir)
+		if errors.Is(err, errors.ErrUnsupported) {
+			return "free space is not available on this platform", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		detail := fmt.Sprintf("%s free in %s", formatBytes(free), dir)
+		if free < minFree {
+			return detail, fmt.Errorf("%s free in %s, less than %s", formatBytes(free), dir, formatBytes(minFree))
+		}
+		return detail, nil
+	}
+}
+
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,130 @@
+// Package health runs the dependency checks behind the readiness probe.
+// Checks are registered by name, run concurrently with a timeout and their results are cached,
+// so a probe hitting the service every second doesn't turn into a query per second on the storage.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of a check result
+const (
+	StatusOK      = "ok"
+	StatusFailing = "failing"
+)
+
+// Check reports whether a dependency works, detail is an optional human readable note like the free space left.
+// It must return when ctx is done.
+type Check func(ctx context.Context) (detail string, err error)
+
+// Result of the last run of a check
+type Result struct {
+	Status     string    `json:"status"` // ok or failing
+	Detail     string    `json:"detail,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS float64   `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at"` // older than now when the result comes from the cache
+}
+
+type entry struct {
+	name    string
+	check   Check
+	mu      sync.Mutex // held while the check runs, concurrent probes wait for its result
+	result  Result
+	expires time.Time
+}
+
+// Registry holds the checks, it is safe for concurrent use
+type Registry struct {
+	ttl     time.Duration
+	timeout time.Duration
+
+	mu           sync.RWMutex
+	entries      []*entry
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry caches results for ttl, 0 runs the checks on every call, and gives each check timeout to finish
+func NewRegistry(ttl, timeout time.Duration) *Registry {
+	return &Registry{ttl: ttl, timeout: timeout}
+}
+
+// Register adds a check, a check registered twice under the same name replaces the first one
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, e := range r.entries {
+		if e.name == name {
+			r.entries[i] = &entry{name: name, check: check}
+			return
+		}
+	}
+	r.entries = append(r.entries, &entry{name: name, check: check})
+}
+
+// ShutDown marks the service as going away, the readiness probe fails from now on whatever the checks say
+func (r *Registry) ShutDown() {
+	r.shuttingDown.Store(true)
+}
+
+func (r *Registry) ShuttingDown() bool {
+	return r.shuttingDown.Load()
+}
+
+// Run returns the result of every check by name, ok is false when any of them is failing
+func (r *Registry) Run(ctx context.Context) (results map[string]Result, ok bool) {
+	r.mu.RLock()
+	entries := append([]*entry(nil), r.entries...)
+	r.mu.RUnlock()
+
+	results = make(map[string]Result, len(entries))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := r.run(ctx, e)
+			mu.Lock()
+			results[e.name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	ok = true
+	for _, result := range results {
+		if result.Status != StatusOK {
+			ok = false
+		}
+	}
+	return results, ok
+}
+
+// run returns the cached result of e or runs the check when it has expired
+func (r *Registry) run(ctx context.Context, e *entry) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if time.Now().Before(e.expires) {
+		return e.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	start := time.Now()
+	detail, err := e.check(ctx)
+	result := Result{
+		Status:     StatusOK,
+		Detail:     detail,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt:  start.UTC(),
+	}
+	if err != nil {
+		result.Status, result.Error = StatusFailing, err.Error()
+	}
+	e.result, e.expires = result, start.Add(r.ttl)
+	return result
+}
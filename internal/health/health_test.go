@@ -0,0 +1,156 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingCheck counts its runs and fails while err is set
+type countingCheck struct {
+	runs atomic.Int32
+	err  error
+}
+
+func (c *countingCheck) check(ctx context.Context) (string, error) {
+	c.runs.Add(1)
+	return "fine", c.err
+}
+
+func TestRegistryRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks map[string]error
+		ok     bool
+	}{
+		{"no checks", nil, true},
+		{"all ok", map[string]error{"storage": nil, "disk": nil}, true},
+		{"one failing", map[string]error{"storage": errors.New("closed"), "disk": nil}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(0, time.Second)
+			for name, err := range tt.checks {
+				check := &countingCheck{err: err}
+				r.Register(name, check.check)
+			}
+			results, ok := r.Run(context.Background())
+			if ok != tt.ok || len(results) != len(tt.checks) {
+				t.Fatalf("ok = %v with %d results, want %v with %d", ok, len(results), tt.ok, len(tt.checks))
+			}
+			for name, err := range tt.checks {
+				result := results[name]
+				if err == nil && (result.Status != StatusOK || result.Error != "") {
+					t.Errorf("%s = %+v, want ok", name, result)
+				}
+				if err != nil && (result.Status != StatusFailing || result.Error != err.Error()) {
+					t.Errorf("%s = %+v, want failing with %q", name, result, err)
+				}
+				if result.Detail != "fine" {
+					t.Errorf("%s detail = %q, want fine", name, result.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistryCachesResults(t *testing.T) {
+	r := NewRegistry(time.Hour, time.Second)
+	check := &countingCheck{}
+	r.Register("storage", check.check)
+
+	// concurrent probes wait for the running check instead of starting their own
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Run(context.Background())
+		}()
+	}
+	wg.Wait()
+	check.err = errors.New("closed")
+	if _, ok := r.Run(context.Background()); !ok {
+		t.Error("cached result was not used")
+	}
+	if n := check.runs.Load(); n != 1 {
+		t.Errorf("check ran %d times, want 1", n)
+	}
+
+	// registering again replaces the check and its cached result
+	r.Register("storage", check.check)
+	if _, ok := r.Run(context.Background()); ok {
+		t.Error("replaced check still has the old result")
+	}
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	r := NewRegistry(0, 10*time.Millisecond)
+	r.Register("slow", func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	results, ok := r.Run(context.Background())
+	if ok || !strings.Contains(results["slow"].Error, "deadline") {
+		t.Errorf("ok = %v, result %+v, want failing on the deadline", ok, results["slow"])
+	}
+}
+
+func TestShutDown(t *testing.T) {
+	r := NewRegistry(0, time.Second)
+	if r.ShuttingDown() {
+		t.Fatal("shutting down from the start")
+	}
+	r.ShutDown()
+	if !r.ShuttingDown() {
+		t.Error("ShutDown was not recorded")
+	}
+}
+
+func TestDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := DiskSpace(dir, 0)(context.Background()); err != nil {
+		t.Errorf("no minimum: %v", err)
+	}
+	detail, err := DiskSpace(dir, math.MaxUint64)(context.Background())
+	if err == nil && !strings.Contains(detail, "not available") {
+		t.Errorf("more than any disk has: got %q, want an error", detail)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes uint64
+		want  string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{100 << 20, "100.0 MiB"},
+		{3 << 40, "3.0 TiB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.bytes); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	info := Build("1.2.3")
+	if info.Version != "1.2.3" || info.GoVersion == "" || info.Commit == "" || info.BuildTime == "" {
+		t.Errorf("build info %+v has empty fields", info)
+	}
+
+	Commit, BuildTime = "abc123", "2025-01-01T00:00:00Z"
+	defer func() { Commit, BuildTime = "", "" }()
+	if info := Build("1.2.3"); info.Commit != "abc123" || info.BuildTime != "2025-01-01T00:00:00Z" {
+		t.Errorf("linker values were not used: %+v", info)
+	}
+}
@@ -1,14 +1,21 @@
 package logger
 
 import (
+	"context"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 
 	"CalculatorWebService/internal/config"
+	"CalculatorWebService/internal/requestid"
 )
 
 var Logger *logrus.Logger
@@ -67,7 +74,7 @@ func LoggingMiddleware() gin.HandlerFunc {
 		statusCode := c.Writer.Status()
 		bodySize := c.Writer.Size()
 
-		entry := Logger.WithFields(logrus.Fields{
+		entry := WithContext(c.Request.Context()).WithFields(logrus.Fields{
 			"method":     method,
 			"path":       path,
 			"query":      raw,
@@ -88,6 +95,68 @@ func LoggingMiddleware() gin.HandlerFunc {
 	}
 }
 
+// UnaryServerInterceptor is the gRPC counterpart of LoggingMiddleware
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor logs a stream once it's finished
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	fields := logrus.Fields{
+		"method":  method,
+		"code":    code.String(),
+		"latency": time.Since(start),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields["client_ip"] = p.Addr.String()
+	}
+	entry := WithContext(ctx).WithFields(fields)
+	if err != nil {
+		entry = entry.WithField("error", status.Convert(err).Message())
+	}
+
+	switch code {
+	case codes.OK:
+		entry.Info("gRPC call completed successfully")
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		entry.Error("gRPC call completed with server error")
+	default:
+		entry.Warn("gRPC call completed with client error")
+	}
+}
+
+// WithContext returns an entry carrying the request ID of ctx, so everything logged while serving
+// a request can be correlated with the access log line and the X-Request-ID the client got.
+// When ctx is part of a trace the entry also carries trace_id and span_id.
+func WithContext(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(Logger)
+	if id := requestid.FromContext(ctx); id != "" {
+		entry = entry.WithField("request_id", id)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		entry = entry.WithFields(logrus.Fields{
+			"trace_id": span.TraceID().String(),
+			"span_id":  span.SpanID().String(),
+		})
+	}
+	return entry
+}
+
 func LogInfo(message string, fields ...logrus.Fields) {
 	if len(fields) > 0 {
 		Logger.WithFields(fields[0]).Info(message)
@@ -105,6 +174,11 @@ func LogWarn(message string, fields ...logrus.Fields) {
 }
 
 func LogError(message string, err error, fields ...logrus.Fields) {
+	LogErrorContext(context.Background(), message, err, fields...)
+}
+
+// LogErrorContext is LogError for code serving a request, the entry carries the request ID
+func LogErrorContext(ctx context.Context, message string, err error, fields ...logrus.Fields) {
 	logFields := logrus.Fields{}
 	if err != nil {
 		logFields["error"] = err.Error()
@@ -114,5 +188,5 @@ func LogError(message string, err error, fields ...logrus.Fields) {
 			logFields[k] = v
 		}
 	}
-	Logger.WithFields(logFields).Error(message)
+	WithContext(ctx).WithFields(logFields).Error(message)
 }
@@ -1,36 +1,52 @@
 package metrics
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 
 	"CalculatorWebService/internal/config"
+	"CalculatorWebService/internal/requestid"
 )
 
 type Metrics struct {
 	reg        *prometheus.Registry
 	Handler    *http.Handler
 	Counters   map[string]*prometheus.CounterVec
+	Histograms map[string]*prometheus.HistogramVec
+	Summaries  map[string]*prometheus.SummaryVec
+	Gauges     map[string]*prometheus.GaugeVec
+	buckets    map[string][]float64 // histogram buckets, see SetBuckets
 	baseLabels prometheus.Labels
 	mu         sync.RWMutex
 }
 
 func NewMetrics(initConfig config.MetricsConfig) *Metrics {
 	reg := prometheus.NewRegistry()
-	handler := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+	// OpenMetrics is only served to scrapers asking for it, it's the format carrying exemplars
+	handler := promhttp.HandlerFor(reg, promhttp.HandlerOpts{EnableOpenMetrics: true})
 	m := &Metrics{
 		reg:        reg,
 		Handler:    &handler,
 		Counters:   make(map[string]*prometheus.CounterVec),
+		Histograms: make(map[string]*prometheus.HistogramVec),
+		Summaries:  make(map[string]*prometheus.SummaryVec),
+		Gauges:     make(map[string]*prometheus.GaugeVec),
+		buckets:    make(map[string][]float64),
 		baseLabels: make(prometheus.Labels),
 	}
 	m.SetupBaseLabels(initConfig)
+	m.SetBuckets("http_request_duration_seconds", LatencyBuckets)
+	m.SetBuckets("grpc_request_duration_seconds", LatencyBuckets)
 	return m
 }
 func (m *Metrics) SetupBaseLabels(config config.MetricsConfig) {
@@ -70,50 +86,105 @@ func (m *Metrics) CountAdd(metricName string, labels prometheus.Labels, addValue
 	metric.With(labels).Add(addValue)
 }
 
-func (m *Metrics) newCounter(metricName string, labels prometheus.Labels) *prometheus.CounterVec {
-	labelsNames := make([]string, 0, len(labels))
-	for baseLabel, _ := range m.baseLabels {
-		labelsNames = append(labelsNames, baseLabel)
+// CountIncWithExemplar is CountInc linking the sample to a request, e.g. {"request_id": ...},
+// so a spike on a dashboard leads to the logs of one of the requests behind it. Empty exemplar values are dropped.
+func (m *Metrics) CountIncWithExemplar(metricName string, labels prometheus.Labels, exemplar prometheus.Labels) {
+	metric := m.getCounter(metricName, labels)
+	if metric == nil {
+		return
 	}
-	for labelName, _ := range labels {
-		labelsNames = append(labelsNames, labelName)
+	for baseLabel, baseValue := range m.baseLabels {
+		labels[baseLabel] = baseValue
 	}
-	metric := promauto.With(m.reg).NewCounterVec(prometheus.CounterOpts{
-		Name: metricName,
-		Help: fmt.Sprintf("Counter for %s", metricName),
-	}, labelsNames)
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	if _, exist := m.Counters[metricName]; exist {
-		// someone else created it in the meantime
-		return m.Counters[metricName]
-	} else {
-		m.Counters[metricName] = metric
+	counter := metric.With(labels)
+	if adder, ok := counter.(prometheus.ExemplarAdder); ok {
+		if exemplar, ok := validExemplar(exemplar); ok {
+			adder.AddWithExemplar(1, exemplar)
+			return
+		}
 	}
-	return metric
+	counter.Inc()
 }
 
-func (m *Metrics) getCounter(metricName string, labels prometheus.Labels) *prometheus.CounterVec {
+// GaugeFunc registers a gauge that is read from fn on every scrape, base labels are attached as constant labels.
+func (m *Metrics) GaugeFunc(metricName string, fn func() float64) {
 	if metricName == "" {
-		return nil
-	}
-	m.mu.RLock()
-	metric, exist := m.Counters[metricName]
-	m.mu.RUnlock()
-	if !exist {
-		metric = m.newCounter(metricName, labels)
+		return
 	}
-	return metric
+	promauto.With(m.reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        metricName,
+		Help:        fmt.Sprintf("Gauge for %s", metricName),
+		ConstLabels: m.baseLabels,
+	}, fn)
+}
+
+func (m *Metrics) getCounter(metricName string, labels prometheus.Labels) *prometheus.CounterVec {
+	return vector(m, m.Counters, metricName, func() *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: metricName,
+			Help: fmt.Sprintf("Counter for %s", metricName),
+		}, m.labelNames(labels))
+	})
 }
 
+// PrometheusMiddleware counts requests and records their latency and sizes per route.
+// Routes are gin patterns (/sessions/:id), unknown paths are counted under an empty path.
 func (m *Metrics) PrometheusMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		start := time.Now()
+		m.GaugeAdd("http_requests_in_flight", prometheus.Labels{}, 1)
+		defer m.GaugeAdd("http_requests_in_flight", prometheus.Labels{}, -1)
+
 		c.Next()
-		status := c.Writer.Status()
-		m.CountInc("http_requests_total", prometheus.Labels{
+		status := fmt.Sprintf("%d", c.Writer.Status())
+		exemplar := prometheus.Labels{"request_id": requestid.Get(c)}
+		m.CountIncWithExemplar("http_requests_total", prometheus.Labels{
+			"method": c.Request.Method,
+			"path":   c.FullPath(),
+			"status": status,
+		}, exemplar)
+		m.ObserveWithExemplar("http_request_duration_seconds", prometheus.Labels{
 			"method": c.Request.Method,
 			"path":   c.FullPath(),
-			"status": fmt.Sprintf("%d", status),
-		})
+			"status": status,
+		}, time.Since(start).Seconds(), exemplar)
+		if c.Request.ContentLength > 0 {
+			m.ObserveSummary("http_request_size_bytes", prometheus.Labels{"method": c.Request.Method, "path": c.FullPath()}, float64(c.Request.ContentLength))
+		}
+		if size := c.Writer.Size(); size > 0 {
+			m.ObserveSummary("http_response_size_bytes", prometheus.Labels{"method": c.Request.Method, "path": c.FullPath()}, float64(size))
+		}
+	}
+}
+
+// UnaryServerInterceptor counts gRPC calls the same way PrometheusMiddleware counts HTTP requests
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.countCall(ctx, info.FullMethod, start, err)
+		return resp, err
 	}
 }
+
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.countCall(ss.Context(), info.FullMethod, start, err)
+		return err
+	}
+}
+
+// countCall counts a finished call and records its duration, for streams that's the lifetime of the stream
+func (m *Metrics) countCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err).String()
+	m.CountIncWithExemplar("grpc_requests_total", prometheus.Labels{
+		"method": method,
+		"code":   code,
+	}, prometheus.Labels{"request_id": requestid.FromContext(ctx)})
+	m.ObserveWithExemplar("grpc_request_duration_seconds", prometheus.Labels{
+		"method": method,
+		"code":   code,
+	}, time.Since(start).Seconds(), prometheus.Labels{"request_id": requestid.FromContext(ctx)})
+}
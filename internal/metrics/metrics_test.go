@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"CalculatorWebService/internal/config"
+	"CalculatorWebService/internal/requestid"
+)
+
+// family gathers the metric family called name, nil when nothing was recorded under it
+func family(t *testing.T, m *Metrics, name string) *dto.MetricFamily {
+	t.Helper()
+	families, err := m.reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// labels returns the labels of a sample as a map
+func labels(metric *dto.Metric) map[string]string {
+	pairs := make(map[string]string)
+	for _, pair := range metric.GetLabel() {
+		pairs[pair.GetName()] = pair.GetValue()
+	}
+	return pairs
+}
+
+func TestBaseLabels(t *testing.T) {
+	m := NewMetrics(config.MetricsConfig{ServiceName: "calculator", ServerName: "host-1"})
+	m.CountInc("calculations_total", prometheus.Labels{"operation": "addition"})
+	m.CountAdd("calculations_total", prometheus.Labels{"operation": "addition"}, 2)
+
+	f := family(t, m, "calculations_total")
+	if f == nil || len(f.GetMetric()) != 1 {
+		t.Fatalf("family = %v, want one sample", f)
+	}
+	sample := f.GetMetric()[0]
+	want := map[string]string{"operation": "addition", "service": "calculator", "server": "host-1"}
+	if got := labels(sample); len(got) != len(want) || got["operation"] != want["operation"] || got["service"] != want["service"] || got["server"] != want["server"] {
+		t.Errorf("labels = %v, want %v", got, want)
+	}
+	if v := sample.GetCounter().GetValue(); v != 3 {
+		t.Errorf("value = %v, want 3", v)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	m := NewMetrics(config.MetricsConfig{})
+	m.Observe("http_request_duration_seconds", prometheus.Labels{"path": "/"}, 0.002)
+	m.Observe("storage_duration_seconds", prometheus.Labels{"op": "store"}, 0.002)
+
+	tests := []struct {
+		name    string
+		buckets []float64
+	}{
+		{"http_request_duration_seconds", LatencyBuckets},
+		{"storage_duration_seconds", prometheus.DefBuckets},
+	}
+	for _, tt := range tests {
+		f := family(t, m, tt.name)
+		if f == nil {
+			t.Fatalf("%s was not recorded", tt.name)
+		}
+		histogram := f.GetMetric()[0].GetHistogram()
+		if len(histogram.GetBucket()) != len(tt.buckets) || histogram.GetBucket()[0].GetUpperBound() != tt.buckets[0] {
+			t.Errorf("%s has %d buckets from %v, want %d from %v", tt.name, len(histogram.GetBucket()), histogram.GetBucket()[0].GetUpperBound(), len(tt.buckets), tt.buckets[0])
+		}
+	}
+}
+
+func TestValidExemplar(t *testing.T) {
+	tests := []struct {
+		name     string
+		exemplar prometheus.Labels
+		want     int
+		ok       bool
+	}{
+		{"request ID", prometheus.Labels{"request_id": "abc"}, 1, true},
+		{"empty value dropped", prometheus.Labels{"request_id": "abc", "trace_id": ""}, 1, true},
+		{"nothing left", prometheus.Labels{"request_id": ""}, 0, false},
+		{"none", nil, 0, false},
+		{"over the limit", prometheus.Labels{"request_id": strings.Repeat("a", prometheus.ExemplarMaxRunes)}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exemplar, ok := validExemplar(tt.exemplar)
+			if ok != tt.ok || len(exemplar) != tt.want {
+				t.Errorf("got %v, %v, want %d labels and %v", exemplar, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestExemplars(t *testing.T) {
+	m := NewMetrics(config.MetricsConfig{})
+	m.CountIncWithExemplar("requests_total", prometheus.Labels{"path": "/"}, prometheus.Labels{"request_id": "abc"})
+	// an exemplar the client library would panic on is dropped, the count is kept
+	m.CountIncWithExemplar("requests_total", prometheus.Labels{"path": "/"}, prometheus.Labels{"request_id": strings.Repeat("a", 200)})
+
+	counter := family(t, m, "requests_total").GetMetric()[0].GetCounter()
+	if counter.GetValue() != 2 {
+		t.Errorf("value = %v, want 2", counter.GetValue())
+	}
+	if got := labels(&dto.Metric{Label: counter.GetExemplar().GetLabel()}); got["request_id"] != "abc" {
+		t.Errorf("exemplar = %v, want request_id abc", got)
+	}
+}
+
+func TestPrometheusMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMetrics(config.MetricsConfig{})
+	router := gin.New()
+	router.Use(requestid.Middleware(), m.PrometheusMiddleware())
+	router.POST("/sessions/:id", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	for _, path := range []string{"/sessions/1", "/sessions/2", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, strings.NewReader("1+1")))
+	}
+
+	counts := make(map[string]float64)
+	for _, sample := range family(t, m, "http_requests_total").GetMetric() {
+		l := labels(sample)
+		counts[l["path"]+" "+l["status"]] = sample.GetCounter().GetValue()
+	}
+	if counts["/sessions/:id 200"] != 2 || counts[" 404"] != 1 || len(counts) != 2 {
+		t.Errorf("requests = %v, want 2 under the route pattern and 1 under an empty path", counts)
+	}
+	for _, name := range []string{"http_request_duration_seconds", "http_request_size_bytes", "http_response_size_bytes"} {
+		if family(t, m, name) == nil {
+			t.Errorf("%s was not recorded", name)
+		}
+	}
+	if inFlight := family(t, m, "http_requests_in_flight").GetMetric()[0].GetGauge().GetValue(); inFlight != 0 {
+		t.Errorf("%v requests in flight after the last one", inFlight)
+	}
+}
+
+func TestConcurrentFirstUse(t *testing.T) {
+	m := NewMetrics(config.MetricsConfig{})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.CountInc("calculations_total", prometheus.Labels{"operation": "addition"})
+			m.Observe("evaluation_duration_seconds", prometheus.Labels{"operation": "addition"}, 0.001)
+		}()
+	}
+	wg.Wait()
+	if v := family(t, m, "calculations_total").GetMetric()[0].GetCounter().GetValue(); v != 20 {
+		t.Errorf("value = %v, want 20", v)
+	}
+}
@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LatencyBuckets are the buckets of the duration histograms in seconds, from 0.5ms to 10s.
+// Calculations take microseconds, the low buckets keep them apart from storage round-trips.
+var LatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// SummaryObjectives are the quantiles of every summary with their allowed error: median, p90 and p99
+var SummaryObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
+
+// SetBuckets sets the buckets of a histogram, it must be called before the first observation.
+// Histograms without buckets use prometheus.DefBuckets.
+func (m *Metrics) SetBuckets(metricName string, buckets []float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buckets[metricName] = buckets
+}
+
+// Observe adds value to a histogram, created on the fly like the counters of CountInc
+func (m *Metrics) Observe(metricName string, labels prometheus.Labels, value float64) {
+	m.ObserveWithExemplar(metricName, labels, value, nil)
+}
+
+// ObserveWithExemplar is Observe linking the observation to a request, see CountIncWithExemplar
+func (m *Metrics) ObserveWithExemplar(metricName string, labels prometheus.Labels, value float64, exemplar prometheus.Labels) {
+	metric := m.getHistogram(metricName, labels)
+	if metric == nil {
+		return
+	}
+	observer := metric.With(m.withBaseLabels(labels))
+	if adder, ok := observer.(prometheus.ExemplarObserver); ok {
+		if exemplar, ok := validExemplar(exemplar); ok {
+			adder.ObserveWithExemplar(value, exemplar)
+			return
+		}
+	}
+	observer.Observe(value)
+}
+
+// ObserveSummary adds value to a summary with the SummaryObjectives quantiles
+func (m *Metrics) ObserveSummary(metricName string, labels prometheus.Labels, value float64) {
+	metric := m.getSummary(metricName, labels)
+	if metric == nil {
+		return
+	}
+	metric.With(m.withBaseLabels(labels)).Observe(value)
+}
+
+// GaugeSet and GaugeAdd change a labelled gauge, GaugeFunc is simpler for values that can be read on scrape
+func (m *Metrics) GaugeSet(metricName string, labels prometheus.Labels, value float64) {
+	metric := m.getGauge(metricName, labels)
+	if metric == nil {
+		return
+	}
+	metric.With(m.withBaseLabels(labels)).Set(value)
+}
+
+func (m *Metrics) GaugeAdd(metricName string, labels prometheus.Labels, value float64) {
+	metric := m.getGauge(metricName, labels)
+	if metric == nil {
+		return
+	}
+	metric.With(m.withBaseLabels(labels)).Add(value)
+}
+
+func (m *Metrics) getHistogram(metricName string, labels prometheus.Labels) *prometheus.HistogramVec {
+	return vector(m, m.Histograms, metricName, func() *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    metricName,
+			Help:    fmt.Sprintf("Histogram for %s", metricName),
+			Buckets: m.buckets[metricName],
+		}, m.labelNames(labels))
+	})
+}
+
+func (m *Metrics) getSummary(metricName string, labels prometheus.Labels) *prometheus.SummaryVec {
+	return vector(m, m.Summaries, metricName, func() *prometheus.SummaryVec {
+		return prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Name:       metricName,
+			Help:       fmt.Sprintf("Summary for %s", metricName),
+			Objectives: SummaryObjectives,
+		}, m.labelNames(labels))
+	})
+}
+
+func (m *Metrics) getGauge(metricName string, labels prometheus.Labels) *prometheus.GaugeVec {
+	return vector(m, m.Gauges, metricName, func() *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName,
+			Help: fmt.Sprintf("Gauge for %s", metricName),
+		}, m.labelNames(labels))
+	})
+}
+
+// vector returns the vector registered under metricName, newVector creates and registers it on first use.
+// Creation happens under the write lock, so concurrent first observations register it once.
+func vector[V prometheus.Collector](m *Metrics, vectors map[string]V, metricName string, newVector func() V) V {
+	var none V
+	if metricName == "" {
+		return none
+	}
+	m.mu.RLock()
+	metric, exist := vectors[metricName]
+	m.mu.RUnlock()
+	if exist {
+		return metric
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if metric, exist := vectors[metricName]; exist {
+		return metric
+	}
+	metric = newVector()
+	m.reg.MustRegister(metric)
+	vectors[metricName] = metric
+	return metric
+}
+
+// labelNames are the names of the base labels and of labels, the label set of a vector is fixed by its first use
+func (m *Metrics) labelNames(labels prometheus.Labels) []string {
+	names := make([]string, 0, len(m.baseLabels)+len(labels))
+	for baseLabel := range m.baseLabels {
+		names = append(names, baseLabel)
+	}
+	for labelName := range labels {
+		names = append(names, labelName)
+	}
+	return names
+}
+
+// withBaseLabels adds the base labels to labels and returns it
+func (m *Metrics) withBaseLabels(labels prometheus.Labels) prometheus.Labels {
+	for baseLabel, baseValue := range m.baseLabels {
+		labels[baseLabel] = baseValue
+	}
+	return labels
+}
+
+// validExemplar drops empty values, false when nothing is left or the exemplar is over the OpenMetrics limit:
+// the client library panics on those and the observation matters more than its exemplar
+func validExemplar(exemplar prometheus.Labels) (prometheus.Labels, bool) {
+	runes := 0
+	for name, value := range exemplar {
+		if value == "" {
+			delete(exemplar, name)
+			continue
+		}
+		runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+	}
+	return exemplar, len(exemplar) > 0 && runes <= prometheus.ExemplarMaxRunes
+}
@@ -0,0 +1,58 @@
+package negotiate
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"CalculatorWebService/internal/problem"
+)
+
+// Consumes lists the request body media types Bind understands, for the API description
+var Consumes = []string{MIMEJSON, MIMEForm, MIMEMultipartForm, MIMEMsgPack, MIMEMsgPack2}
+
+// Bind decodes the request into obj according to its Content-Type and validates it like gin's ShouldBind.
+// Requests without a body are bound from the query string, bodies without a Content-Type are taken as JSON.
+// An unsupported Content-Type is an UnsupportedMediaType problem.
+func Bind(c *gin.Context, obj interface{}) error {
+	if c.Request.ContentLength == 0 {
+		return c.ShouldBindWith(obj, binding.Query)
+	}
+	switch contentType := c.ContentType(); contentType {
+	case "", MIMEJSON:
+		return c.ShouldBindWith(obj, binding.JSON)
+	case MIMEForm:
+		return c.ShouldBindWith(obj, binding.Form)
+	case MIMEMultipartForm:
+		return c.ShouldBindWith(obj, binding.FormMultipart)
+	case MIMEMsgPack, MIMEMsgPack2:
+		body, err := decodeMsgPack(c.Request.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		if err != nil {
+			return problem.MalformedBody.New("request body is not valid MessagePack: " + err.Error())
+		}
+		return binding.JSON.BindBody(body, obj)
+	default:
+		return problem.UnsupportedMediaType.New("Content-Type " + contentType + " is not supported, use one of " + strings.Join(Consumes, ", "))
+	}
+}
+
+// LimitBody rejects request bodies larger than limit bytes with 413. A body announced as larger is refused
+// before the handler runs, a longer one without Content-Length makes Bind fail once limit bytes are read.
+func LimitBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			problem.Respond(c, problem.PayloadTooLarge, "request body is larger than "+strconv.FormatInt(limit, 10)+" bytes")
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
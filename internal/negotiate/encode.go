@@ -0,0 +1,229 @@
+package negotiate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ugorji/go/codec"
+)
+
+// xmlRoot and xmlItem name the document element and the elements of arrays
+const (
+	xmlRoot = "response"
+	xmlItem = "item"
+)
+
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.RawToString = true
+	h.WriteExt = true
+	h.Canonical = true // sorted map keys, equal values encode to equal bytes
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	return h
+}()
+
+type xmlRender struct {
+	data interface{}
+}
+
+func (r xmlRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", MIMEXML+"; charset=utf-8")
+}
+
+// Render transcodes the JSON encoding: objects become elements named after their keys,
+// array elements are <item> and null is an empty element.
+func (r xmlRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	data, err := json.Marshal(r.data)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if err := writeXML(enc, dec, xmlRoot); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func writeXML(enc *xml.Encoder, dec *json.Decoder, name string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	start := xmlStart(name)
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		for dec.More() {
+			child := xmlItem
+			if v == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				child = key.(string)
+			}
+			if err := writeXML(enc, dec, child); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil { // closing delimiter
+			return err
+		}
+	case string:
+		err = enc.EncodeToken(xml.CharData(v))
+	case json.Number:
+		err = enc.EncodeToken(xml.CharData(v))
+	case bool:
+		text := "false"
+		if v {
+			text = "true"
+		}
+		err = enc.EncodeToken(xml.CharData(text))
+	}
+	if err != nil {
+		return err
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// xmlStart uses the key as element name, keys that are not XML names go into a key attribute of an <entry>
+func xmlStart(name string) xml.StartElement {
+	if validXMLName(name) {
+		return xml.StartElement{Name: xml.Name{Local: name}}
+	}
+	return xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}}}
+}
+
+func validXMLName(name string) bool {
+	if name == "" {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(name)
+	if !unicode.IsLetter(first) && first != '_' {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+type msgpackRender struct {
+	data interface{}
+}
+
+func (r msgpackRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", MIMEMsgPack)
+}
+
+// Render transcodes the JSON encoding, so MessagePack maps carry the JSON field names
+func (r msgpackRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	data, err := json.Marshal(r.data)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return err
+	}
+	return codec.NewEncoder(w, msgpackHandle).Encode(plainNumbers(value))
+}
+
+// plainNumbers replaces json.Number, a string to the MessagePack encoder, with integers or floats
+func plainNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = plainNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = plainNumbers(item)
+		}
+	}
+	return value
+}
+
+type csvRender struct {
+	table Table
+}
+
+func (r csvRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", MIMECSV+"; charset=utf-8")
+}
+
+func (r csvRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	out := csv.NewWriter(w)
+	if err := out.Write(r.table.CSVHeader()); err != nil {
+		return err
+	}
+	for _, row := range r.table.CSVRows() {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = escapeFormula(cell)
+		}
+		if err := out.Write(cells); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// formulaPrefixes make spreadsheet applications read a cell as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula defuses cells a spreadsheet would run, e.g. a User-Agent of =HYPERLINK(...), with a leading quote.
+// Numbers like -5 are no formula and stay numbers.
+func escapeFormula(cell string) string {
+	if cell == "" || !strings.ContainsRune(formulaPrefixes, rune(cell[0])) {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
+
+// decodeMsgPack transcodes a MessagePack body to JSON, the result is bound like a JSON body
+func decodeMsgPack(body io.Reader) ([]byte, error) {
+	// the streaming decoder misses a string ending exactly at the end of the body, decode from memory
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := codec.NewDecoderBytes(data, msgpackHandle).Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
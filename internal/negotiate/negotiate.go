@@ -0,0 +1,161 @@
+// Package negotiate picks the request and response formats from the Content-Type and Accept headers.
+// Requests are accepted as JSON, form-encoded, query-string or MessagePack, responses are written as
+// JSON, XML, MessagePack or CSV. Every format is derived from the JSON encoding of the Go types,
+// so field names and omitted fields are the same whatever the client asked for.
+package negotiate
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+
+	"CalculatorWebService/internal/problem"
+)
+
+// Media types of the supported formats
+const (
+	MIMEJSON          = "application/json"
+	MIMEXML           = "application/xml"
+	MIMEXML2          = "text/xml"
+	MIMEMsgPack       = "application/msgpack"
+	MIMEMsgPack2      = "application/x-msgpack"
+	MIMECSV           = "text/csv"
+	MIMEForm          = "application/x-www-form-urlencoded"
+	MIMEMultipartForm = "multipart/form-data"
+)
+
+// Table is implemented by responses that can be exported as CSV: a header row and one row per record
+type Table interface {
+	CSVHeader() []string
+	CSVRows() [][]string
+}
+
+// format is a response format, the first media type is the one sent in Content-Type
+type format struct {
+	mediaTypes []string
+	// supports reports whether obj can be written in the format, nil stands for a response not known yet
+	supports func(obj interface{}) bool
+	render   func(obj interface{}) render.Render
+}
+
+func always(interface{}) bool { return true }
+
+// formats in order of preference, JSON wins when the client accepts anything
+var formats = []format{
+	{mediaTypes: []string{MIMEJSON}, supports: always, render: func(obj interface{}) render.Render { return render.JSON{Data: obj} }},
+	{mediaTypes: []string{MIMEXML, MIMEXML2}, supports: always, render: func(obj interface{}) render.Render { return xmlRender{data: obj} }},
+	{mediaTypes: []string{MIMEMsgPack, MIMEMsgPack2}, supports: always, render: func(obj interface{}) render.Render { return msgpackRender{data: obj} }},
+	{mediaTypes: []string{MIMECSV}, supports: isTable, render: func(obj interface{}) render.Render { return csvRender{table: obj.(Table)} }},
+}
+
+func isTable(obj interface{}) bool {
+	if obj == nil {
+		return true
+	}
+	_, ok := obj.(Table)
+	return ok
+}
+
+// Produces lists the media types obj can be written as, for the API description
+func Produces(obj interface{}) []string {
+	var types []string
+	for _, f := range formats {
+		if f.supports(obj) {
+			types = append(types, f.mediaTypes...)
+		}
+	}
+	return types
+}
+
+// Middleware rejects requests with 406 before the handler runs when none of the accepted media types
+// can be produced for the response of the route. response returns a value of the type the matched route
+// answers with, nil when it is not known; then only formats that can't write it are left to Render to refuse.
+func Middleware(response func(c *gin.Context) interface{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		obj := response(c)
+		if _, ok := choose(c.GetHeader("Accept"), obj); !ok {
+			notAcceptable(c, obj)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Render writes obj in the format preferred by the Accept header of the request
+func Render(c *gin.Context, status int, obj interface{}) {
+	f, ok := choose(c.GetHeader("Accept"), obj)
+	if !ok {
+		notAcceptable(c, obj)
+		return
+	}
+	c.Header("Vary", "Accept")
+	c.Render(status, f.render(obj))
+}
+
+func notAcceptable(c *gin.Context, obj interface{}) {
+	problem.Respond(c, problem.NotAcceptable, "Accept must allow one of "+strings.Join(Produces(obj), ", "))
+}
+
+// mediaRange is an entry of the Accept header, e.g. text/* with its quality
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+func (r mediaRange) matches(mediaType string) bool {
+	switch {
+	case r.mediaType == "*/*" || r.mediaType == mediaType:
+		return true
+	case strings.HasSuffix(r.mediaType, "/*"):
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(r.mediaType, "*"))
+	}
+	return false
+}
+
+// choose returns the first format matching the media ranges by descending quality, JSON when there is no Accept header
+func choose(accept string, obj interface{}) (format, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return formats[0], true
+	}
+	for _, r := range parseAccept(accept) {
+		for _, f := range formats {
+			if !f.supports(obj) {
+				continue
+			}
+			for _, mediaType := range f.mediaTypes {
+				if r.matches(mediaType) {
+					return f, true
+				}
+			}
+		}
+	}
+	return format{}, false
+}
+
+// parseAccept returns the media ranges with a positive quality, best first
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		r := mediaRange{mediaType: strings.ToLower(strings.TrimSpace(params[0])), quality: 1}
+		if r.mediaType == "" {
+			continue
+		}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					r.quality = q
+				}
+			}
+		}
+		if r.quality > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+	return ranges
+}
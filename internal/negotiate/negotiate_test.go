@@ -0,0 +1,265 @@
+package negotiate
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+type row struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// rows is a Table, row is not
+type rows struct {
+	Rows []row `json:"rows"`
+}
+
+func (r rows) CSVHeader() []string { return []string{"name", "value"} }
+func (r rows) CSVRows() [][]string {
+	cells := make([][]string, len(r.Rows))
+	for i, row := range r.Rows {
+		cells[i] = []string{row.Name, formatValue(row.Value)}
+	}
+	return cells
+}
+
+func formatValue(v float64) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestChoose(t *testing.T) {
+	tests := []struct {
+		accept string
+		obj    interface{}
+		want   string // media type sent, empty for 406
+	}{
+		{accept: "", obj: row{}, want: MIMEJSON},
+		{accept: "*/*", obj: row{}, want: MIMEJSON},
+		{accept: "application/xml", obj: row{}, want: MIMEXML},
+		{accept: "text/xml", obj: row{}, want: MIMEXML},
+		{accept: "application/x-msgpack", obj: row{}, want: MIMEMsgPack},
+		{accept: "text/csv", obj: rows{}, want: MIMECSV},
+		{accept: "text/csv", obj: row{}, want: ""},
+		{accept: "text/csv, application/json;q=0.5", obj: row{}, want: MIMEJSON},
+		{accept: "application/json;q=0.2, application/xml", obj: row{}, want: MIMEXML},
+		{accept: "text/*", obj: rows{}, want: MIMEXML},
+		{accept: "application/json;q=0, text/html", obj: row{}, want: ""},
+		{accept: "image/png", obj: nil, want: ""},
+		{accept: "text/csv", obj: nil, want: MIMECSV}, // unknown response, left to Render
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			f, ok := choose(tt.accept, tt.obj)
+			got := ""
+			if ok {
+				got = f.mediaTypes[0]
+			}
+			if got != tt.want {
+				t.Errorf("choose(%q, %T) = %q, want %q", tt.accept, tt.obj, got, tt.want)
+			}
+		})
+	}
+}
+
+func serve(t *testing.T, accept string, obj interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) { Render(c, http.StatusOK, obj) })
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRender(t *testing.T) {
+	obj := rows{Rows: []row{{Name: "a", Value: 1.5}, {Name: "b & c", Value: -2}}}
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{MIMEJSON, "application/json; charset=utf-8", `{"rows":[{"name":"a","value":1.5},{"name":"b \u0026 c","value":-2}]}`},
+		{MIMEXML, "application/xml; charset=utf-8",
+			`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<response><rows><item><name>a</name><value>1.5</value></item><item><name>b &amp; c</name><value>-2</value></item></rows></response>`},
+		{MIMECSV, "text/csv; charset=utf-8", "name,value\na,1.5\nb & c,-2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			w := serve(t, tt.accept, obj)
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tt.contentType {
+				t.Fatalf("status %d, Content-Type %q, want 200, %q", w.Code, w.Header().Get("Content-Type"), tt.contentType)
+			}
+			if got := w.Body.String(); got != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+		})
+	}
+
+	t.Run(MIMEMsgPack, func(t *testing.T) {
+		w := serve(t, MIMEMsgPack, obj)
+		var decoded map[string]interface{}
+		if err := codec.NewDecoderBytes(w.Body.Bytes(), msgpackHandle).Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+		first := decoded["rows"].([]interface{})[0].(map[string]interface{})
+		if first["name"] != "a" || first["value"] != 1.5 {
+			t.Errorf("first row = %v, want the JSON field names and values", first)
+		}
+		second := decoded["rows"].([]interface{})[1].(map[string]interface{})
+		if second["value"] != int64(-2) {
+			t.Errorf("integral value decoded as %T %v, want int64 -2", second["value"], second["value"])
+		}
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		w := serve(t, MIMECSV, row{})
+		if w.Code != http.StatusNotAcceptable || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/problem+json") {
+			t.Errorf("status %d, Content-Type %q, want a 406 problem", w.Code, w.Header().Get("Content-Type"))
+		}
+	})
+}
+
+type bindTarget struct {
+	Name  string  `form:"name" json:"name" binding:"required"`
+	Value float64 `form:"value" json:"value"`
+}
+
+func TestBind(t *testing.T) {
+	msgpack := new(bytes.Buffer)
+	if err := codec.NewEncoder(msgpack, msgpackHandle).Encode(map[string]interface{}{"name": "a", "value": 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		wantErr     bool
+	}{
+		{name: "json", contentType: MIMEJSON, body: `{"name": "a", "value": 1.5}`},
+		{name: "no content type is json", body: `{"name": "a", "value": 1.5}`},
+		{name: "form", contentType: MIMEForm, body: "name=a&value=1.5"},
+		{name: "query", target: "/?name=a&value=1.5"},
+		{name: "msgpack", contentType: MIMEMsgPack, body: msgpack.String()},
+		{name: "invalid msgpack", contentType: MIMEMsgPack, body: "\xc1", wantErr: true},
+		{name: "unsupported", contentType: "text/plain", body: "a 1.5", wantErr: true},
+		{name: "validation", contentType: MIMEJSON, body: `{"value": 1.5}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			if target == "" {
+				target = "/"
+			}
+			req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = req
+
+			var got bindTarget
+			err := Bind(c, &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Bind succeeded with %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != (bindTarget{Name: "a", Value: 1.5}) {
+				t.Errorf("bound %+v", got)
+			}
+		})
+	}
+}
+
+// Regression: the Accept check ran without the response type, so Accept: text/csv reached handlers
+// that can't answer in CSV and they created sessions or functions before failing with 406
+func TestMiddlewareChecksTheRouteResponse(t *testing.T) {
+	responses := map[string]interface{}{"/row": row{}, "/rows": rows{}}
+	router := gin.New()
+	router.Use(Middleware(func(c *gin.Context) interface{} { return responses[c.FullPath()] }))
+	handled := 0
+	handler := func(c *gin.Context) {
+		handled++
+		Render(c, http.StatusOK, responses[c.FullPath()])
+	}
+	router.POST("/row", handler)
+	router.POST("/rows", handler)
+	router.DELETE("/rows", func(c *gin.Context) { handled++; c.Status(http.StatusNoContent) })
+
+	tests := []struct {
+		method, path, accept string
+		status               int
+	}{
+		{http.MethodPost, "/row", MIMECSV, http.StatusNotAcceptable},
+		{http.MethodPost, "/row", "text/csv, application/xml;q=0.1", http.StatusOK},
+		{http.MethodPost, "/row", "image/png", http.StatusNotAcceptable},
+		{http.MethodPost, "/rows", MIMECSV, http.StatusOK},
+		{http.MethodDelete, "/rows", MIMECSV, http.StatusNoContent}, // no response type, nothing to refuse
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path+" "+tt.accept, func(t *testing.T) {
+			handled = 0
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if wantHandled := tt.status != http.StatusNotAcceptable; (handled == 1) != wantHandled {
+				t.Errorf("handler ran %d times, want it to run: %v", handled, wantHandled)
+			}
+		})
+	}
+}
+
+// Regression: client-controlled cells like the User-Agent were exported as is and ran as formulas
+func TestCSVEscapesFormulas(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{`=HYPERLINK("http://evil","x")`, `'=HYPERLINK("http://evil","x")`},
+		{"+1+cmd", "'+1+cmd"},
+		{"-2 ^ 2 = -4", "'-2 ^ 2 = -4"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"-5", "-5"},
+		{"+1.5e3", "+1.5e3"},
+		{"curl/8.0", "curl/8.0"},
+		{"1 + 2 = 3", "1 + 2 = 3"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cell, func(t *testing.T) {
+			if got := escapeFormula(tt.cell); got != tt.want {
+				t.Errorf("escapeFormula(%q) = %q, want %q", tt.cell, got, tt.want)
+			}
+		})
+	}
+
+	w := serve(t, MIMECSV, rows{Rows: []row{{Name: `=HYPERLINK("http://evil","x")`, Value: -1}}})
+	if want := "name,value\n\"'=HYPERLINK(\"\"http://evil\"\",\"\"x\"\")\",-1\n"; w.Body.String() != want {
+		t.Errorf("CSV = %q, want %q", w.Body.String(), want)
+	}
+}
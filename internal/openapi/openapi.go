@@ -0,0 +1,234 @@
+// Package openapi builds an OpenAPI 3 document from route descriptions, schemas of the request
+// and response bodies are derived from the Go types with reflection.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type PathItem struct {
+	Get    *Operation `json:"get,omitempty"`
+	Post   *Operation `json:"post,omitempty"`
+	Put    *Operation `json:"put,omitempty"`
+	Patch  *Operation `json:"patch,omitempty"`
+	Delete *Operation `json:"delete,omitempty"`
+}
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"` // path, query or header
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+	Explode  *bool   `json:"explode,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Route describes a single route. Query, Body and Response are values of the Go types bound from the
+// query string, decoded from the JSON body and written as the response, nil when there is none.
+type Route struct {
+	Method      string
+	Path        string // gin syntax, e.g. /sessions/:id
+	Summary     string
+	Tags        []string
+	Headers     []string // optional request headers
+	Query       interface{}
+	Body        interface{}
+	Response    interface{}
+	Status      int      // success status, 200 when zero
+	ContentType string   // of the success response, application/json when empty
+	Consumes    []string // media types of the body, application/json when empty
+	Produces    []string // media types of the success response when there are several, replaces ContentType
+	Errors      []int    // statuses answered with the error body
+}
+
+// Builder collects routes into a Document
+type Builder struct {
+	doc              *Document
+	schemas          *schemaGenerator
+	errType          interface{}
+	errorContentType string
+}
+
+// NewBuilder starts a document, errorBody is the type of the body sent with error statuses as errorContentType
+func NewBuilder(info Info, errorBody interface{}, errorContentType string) *Builder {
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      make(map[string]*PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+	return &Builder{
+		doc:              doc,
+		schemas:          &schemaGenerator{components: doc.Components.Schemas, types: make(map[string]reflect.Type)},
+		errType:          errorBody,
+		errorContentType: errorContentType,
+	}
+}
+
+func (b *Builder) Add(route Route) {
+	path, params := convertPath(route.Path)
+	op := &Operation{
+		OperationID: operationID(route.Method, path),
+		Summary:     route.Summary,
+		Tags:        route.Tags,
+		Parameters:  params,
+		Responses:   make(map[string]Response),
+	}
+	for _, header := range route.Headers {
+		op.Parameters = append(op.Parameters, Parameter{Name: header, In: "header", Schema: &Schema{Type: "string"}})
+	}
+	if route.Query != nil {
+		op.Parameters = append(op.Parameters, b.schemas.queryParameters(route.Query)...)
+	}
+	if route.Body != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  content(route.Consumes, b.schemas.schema(route.Body)),
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	if route.Response != nil || route.ContentType != "" {
+		mediaTypes := route.Produces
+		if len(mediaTypes) == 0 && route.ContentType != "" {
+			mediaTypes = []string{route.ContentType}
+		}
+		schema := &Schema{Type: "string"}
+		if route.Response != nil {
+			schema = b.schemas.schema(route.Response)
+		}
+		success.Content = content(mediaTypes, schema)
+	}
+	op.Responses[strconv.Itoa(status)] = success
+	for _, code := range route.Errors {
+		op.Responses[strconv.Itoa(code)] = Response{
+			Description: http.StatusText(code),
+			Content:     map[string]MediaType{b.errorContentType: {Schema: b.schemas.schema(b.errType)}},
+		}
+	}
+
+	item := b.doc.Paths[path]
+	if item == nil {
+		item = &PathItem{}
+		b.doc.Paths[path] = item
+	}
+	*item.slot(route.Method) = op
+}
+
+// content maps every media type to the schema, JSON when there are none
+func content(mediaTypes []string, schema *Schema) map[string]MediaType {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{"application/json"}
+	}
+	m := make(map[string]MediaType, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		m[mediaType] = MediaType{Schema: schema}
+	}
+	return m
+}
+
+func (b *Builder) Document() *Document {
+	return b.doc
+}
+
+// Has reports whether the document describes the route, path is in gin syntax
+func (d *Document) Has(method, path string) bool {
+	converted, _ := convertPath(path)
+	item, ok := d.Paths[converted]
+	if !ok {
+		return false
+	}
+	slot := item.slot(method)
+	return slot != nil && *slot != nil
+}
+
+// slot returns where the operation of the method goes, nil for methods OpenAPI paths don't have here
+func (p *PathItem) slot(method string) **Operation {
+	switch method {
+	case http.MethodGet:
+		return &p.Get
+	case http.MethodPost:
+		return &p.Post
+	case http.MethodPut:
+		return &p.Put
+	case http.MethodPatch:
+		return &p.Patch
+	case http.MethodDelete:
+		return &p.Delete
+	}
+	return nil
+}
+
+var pathParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// convertPath turns /sessions/:id into /sessions/{id} and returns the path parameters
+func convertPath(path string) (string, []Parameter) {
+	params := make([]Parameter, 0)
+	converted := pathParam.ReplaceAllStringFunc(path, func(match string) string {
+		name := match[1:]
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		return "{" + name + "}"
+	})
+	return converted, params
+}
+
+// operationID is derived from the method and path, e.g. post /sessions/{id}/evaluate -> postSessionsIdEvaluate
+func operationID(method, path string) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '_' || r == '.'
+	}) {
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return id.String()
+}
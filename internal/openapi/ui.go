@@ -0,0 +1,24 @@
+package openapi
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+)
+
+//go:embed swagger.html
+var swaggerPage string
+
+var swaggerTemplate = template.Must(template.New("swagger").Parse(swaggerPage))
+
+// UIHandler serves a Swagger UI page for the document at specURL. The page itself is embedded,
+// the Swagger UI scripts are loaded by the browser from unpkg.
+func UIHandler(title, specURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := swaggerTemplate.Execute(w, struct{ Title, SpecURL string }{title, specURL})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
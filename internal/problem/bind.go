@@ -0,0 +1,117 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// report fields by the names clients send, not the Go struct field names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				if name := strings.Split(field.Tag.Get(tag), ",")[0]; name != "" && name != "-" {
+					return name
+				}
+			}
+			return field.Name
+		})
+	}
+}
+
+// FromBindError turns a gin binding error into a problem with field-level details where possible
+func FromBindError(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		p := ValidationFailed.New("one or more fields are invalid")
+		for _, fe := range validationErrs {
+			p.Errors = append(p.Errors, FieldError{Field: fe.Field(), Code: fe.Tag(), Message: validationMessage(fe)})
+		}
+		return p
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		p := ValidationFailed.New("one or more fields have the wrong type")
+		field := typeErr.Field
+		if field == "" {
+			field = "(body)"
+		}
+		p.Errors = []FieldError{{Field: field, Code: "type", Message: "must be " + jsonType(typeErr.Type)}}
+		return p
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return PayloadTooLarge.New("request body is larger than " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes")
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return MalformedBody.New(syntaxErr.Error() + " at offset " + strconv.FormatInt(syntaxErr.Offset, 10))
+	}
+	if errors.Is(err, io.EOF) {
+		return MalformedBody.New("request body is empty")
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return MalformedBody.New("request body is truncated")
+	}
+
+	// query and form values that don't parse
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return ValidationFailed.New("'" + numErr.Num + "' is not a valid number")
+	}
+	var timeErr *time.ParseError
+	if errors.As(err, &timeErr) {
+		return ValidationFailed.New("'" + timeErr.Value + "' is not an RFC 3339 time")
+	}
+	return InvalidRequest.New(err.Error())
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of " + fe.Param()
+	default:
+		return "violates the " + fe.Tag() + " rule"
+	}
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
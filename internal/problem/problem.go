@@ -0,0 +1,183 @@
+// Package problem writes RFC 7807 application/problem+json error responses.
+// Every kind of error is a registered Type with a stable code, clients branch on the code
+// (or the type URI derived from it) instead of matching messages.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"CalculatorWebService/internal/requestid"
+)
+
+const (
+	ContentType = "application/problem+json"
+	// TypeBase prefixes the code in type URIs, GET /problems/:code describes the type
+	TypeBase = "/problems/"
+)
+
+// Problem is the error body. Position and Cycle are extension members of specific types.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"` // path of the request
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"` // offending fields of the request
+
+	Position *int     `json:"position,omitempty"` // expression errors: zero-based offset of the offending character
+	Cycle    []string `json:"cycle,omitempty"`    // recursive function definitions: the call cycle
+}
+
+// FieldError points at a single invalid field, Code is the violated rule (required, type, ...)
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Type is a kind of problem. Code, Status and Title never change for a type, Detail explains the occurrence.
+type Type struct {
+	Code   string `json:"code"`
+	Status int    `json:"status"`
+	Title  string `json:"title"`
+	URI    string `json:"type"`
+}
+
+var (
+	typesMu sync.RWMutex
+	types   = make(map[string]Type)
+)
+
+// NewType registers a problem type, meant for package level variables. It panics on duplicate codes.
+func NewType(code string, status int, title string) Type {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	if _, exists := types[code]; exists {
+		panic("problem: type registered twice: " + code)
+	}
+	t := Type{Code: code, Status: status, Title: title, URI: TypeBase + code}
+	types[code] = t
+	return t
+}
+
+// Types returns every registered type sorted by code
+func Types() []Type {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	list := make([]Type, 0, len(types))
+	for _, t := range types {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// Lookup returns the type registered under code
+func Lookup(code string) (Type, bool) {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	t, ok := types[code]
+	return t, ok
+}
+
+// Generic types, domain specific ones are registered by their packages
+var (
+	MalformedBody        = NewType("malformed_body", http.StatusBadRequest, "Request body could not be decoded")
+	ValidationFailed     = NewType("validation_failed", http.StatusBadRequest, "Request failed validation")
+	InvalidRequest       = NewType("invalid_request", http.StatusBadRequest, "Request is invalid")
+	RouteNotFound        = NewType("route_not_found", http.StatusNotFound, "No such endpoint")
+	MethodNotAllowed     = NewType("method_not_allowed", http.StatusMethodNotAllowed, "Method not allowed on this endpoint")
+	NotAcceptable        = NewType("not_acceptable", http.StatusNotAcceptable, "None of the accepted media types can be produced")
+	UnsupportedMediaType = NewType("unsupported_media_type", http.StatusUnsupportedMediaType, "Request body media type is not supported")
+	PayloadTooLarge      = NewType("payload_too_large", http.StatusRequestEntityTooLarge, "Request body is too large")
+	RateLimited          = NewType("rate_limited", http.StatusTooManyRequests, "Too many requests")
+	Internal             = NewType("internal_error", http.StatusInternalServerError, "Internal server error")
+	TypeNotFound         = NewType("problem_type_not_found", http.StatusNotFound, "No such problem type")
+)
+
+// Error makes a problem usable as an error, FromBindError passes such errors through unchanged
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+// New returns a problem of this type, detail describes the occurrence and may be empty
+func (t Type) New(detail string) *Problem {
+	return &Problem{
+		Type:   t.URI,
+		Title:  t.Title,
+		Status: t.Status,
+		Detail: detail,
+		Code:   t.Code,
+	}
+}
+
+// Write replies with the problem and aborts the handler chain, the request ID and path are filled in
+func Write(c *gin.Context, p *Problem) {
+	p.RequestID = requestid.Get(c)
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
+
+// WriteHTTP is Write for code outside of gin handlers, e.g. callbacks of other libraries.
+// The request ID is taken from the response header set by the requestid middleware.
+func WriteHTTP(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.RequestID = w.Header().Get(requestid.Header)
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, p.Title, p.Status)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
+// Respond is a shorthand for writing a new problem of the type
+func Respond(c *gin.Context, t Type, detail string) {
+	Write(c, t.New(detail))
+}
+
+// NoRoute and NoMethod replace gin's plain text 404 and 405
+func NoRoute(c *gin.Context) {
+	Respond(c, RouteNotFound, c.Request.Method+" "+c.Request.URL.Path+" does not exist")
+}
+
+func NoMethod(c *gin.Context) {
+	Respond(c, MethodNotAllowed, c.Request.Method+" is not allowed on "+c.Request.URL.Path)
+}
+
+// TypesResponse lists the problem types, served at GET /problems
+type TypesResponse struct {
+	Types []Type `json:"types"`
+}
+
+// ListTypes serves the catalog of problem types
+func ListTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, TypesResponse{Types: Types()})
+}
+
+// GetType serves a single type, this is where type URIs lead
+func GetType(c *gin.Context) {
+	t, ok := Lookup(c.Param("code"))
+	if !ok {
+		Respond(c, TypeNotFound, "'"+c.Param("code")+"' is not a problem type")
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
@@ -0,0 +1,188 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"CalculatorWebService/internal/config"
+	"CalculatorWebService/internal/logger"
+	"CalculatorWebService/internal/requestid"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	// the panics of TestRecovery are logged as errors, they are expected
+	logger.InitLogger(config.LoggerConfig{Level: "fatal", Format: "text", TimeFormat: time.RFC3339})
+	os.Exit(m.Run())
+}
+
+// serve runs handler behind the request ID middleware and Recovery and decodes the problem it answered with
+func serve(t *testing.T, handler gin.HandlerFunc) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+	router := gin.New()
+	router.Use(requestid.Middleware(), Recovery())
+	router.POST("/calculate/:op", handler)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/calculate/division", nil))
+
+	var p Problem
+	if w.Header().Get("Content-Type") == ContentType {
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatalf("body %q: %v", w.Body.String(), err)
+		}
+	}
+	return w, p
+}
+
+func TestWrite(t *testing.T) {
+	divisionByZero := NewType("test_division_by_zero", http.StatusBadRequest, "Division by zero")
+	w, p := serve(t, func(c *gin.Context) {
+		Respond(c, divisionByZero, "division by zero is not allowed")
+	})
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != ContentType {
+		t.Errorf("status = %d, content type %q, want %d and %q", w.Code, w.Header().Get("Content-Type"), http.StatusBadRequest, ContentType)
+	}
+	want := Problem{
+		Type:      "/problems/test_division_by_zero",
+		Title:     "Division by zero",
+		Status:    http.StatusBadRequest,
+		Detail:    "division by zero is not allowed",
+		Instance:  "/calculate/division",
+		Code:      "test_division_by_zero",
+		RequestID: w.Header().Get(requestid.Header),
+	}
+	if fmt.Sprint(p) != fmt.Sprint(want) {
+		t.Errorf("problem = %+v, want %+v", p, want)
+	}
+}
+
+func TestTypes(t *testing.T) {
+	if got, ok := Lookup("malformed_body"); !ok || got != MalformedBody {
+		t.Errorf("Lookup(malformed_body) = %+v, %v", got, ok)
+	}
+	if _, ok := Lookup("no_such_type"); ok {
+		t.Error("Lookup found an unregistered type")
+	}
+	types := Types()
+	for i := 1; i < len(types); i++ {
+		if types[i-1].Code >= types[i].Code {
+			t.Fatalf("types are not sorted by code: %s before %s", types[i-1].Code, types[i].Code)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a code twice did not panic")
+		}
+	}()
+	NewType("internal_error", http.StatusInternalServerError, "Again")
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		status  int
+		code    string
+	}{
+		{"panic", func(c *gin.Context) { panic("boom") }, http.StatusInternalServerError, Internal.Code},
+		{"panic with an error", func(c *gin.Context) { panic(errors.New("boom")) }, http.StatusInternalServerError, Internal.Code},
+		{"panic after the response started", func(c *gin.Context) {
+			c.String(http.StatusAccepted, "partial")
+			panic("boom")
+		}, http.StatusAccepted, ""},
+		{"client gone", func(c *gin.Context) { panic(fmt.Errorf("write: %w", syscall.EPIPE)) }, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, p := serve(t, tt.handler)
+			if w.Code != tt.status || p.Code != tt.code {
+				t.Errorf("status = %d, problem %q, want %d and %q", w.Code, p.Code, tt.status, tt.code)
+			}
+		})
+	}
+
+	t.Run("abort handler", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != http.ErrAbortHandler {
+				t.Errorf("recovered %v, want http.ErrAbortHandler passed on", r)
+			}
+		}()
+		serve(t, func(c *gin.Context) { panic(http.ErrAbortHandler) })
+	})
+}
+
+type bindRequest struct {
+	Expression string   `json:"expression" binding:"required"`
+	Precision  *int     `json:"precision" binding:"omitempty,min=1,max=1000"`
+	Operands   []string `json:"operands"`
+}
+
+func TestFromBindError(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		code   string
+		detail string
+		errors []FieldError
+	}{
+		{"missing field", `{}`, ValidationFailed.Code, "one or more fields are invalid",
+			[]FieldError{{Field: "expression", Code: "required", Message: "is required"}}},
+		{"out of range", `{"expression": "1", "precision": 0}`, ValidationFailed.Code, "one or more fields are invalid",
+			[]FieldError{{Field: "precision", Code: "min", Message: "must be at least 1"}}},
+		{"wrong type", `{"expression": 1}`, ValidationFailed.Code, "one or more fields have the wrong type",
+			[]FieldError{{Field: "expression", Code: "type", Message: "must be a string"}}},
+		{"wrong element type", `{"expression": "1", "operands": [1]}`, ValidationFailed.Code, "one or more fields have the wrong type",
+			[]FieldError{{Field: "operands.0", Code: "type", Message: "must be a string"}}},
+		{"syntax", `{"expression": }`, MalformedBody.Code, "invalid character '}' looking for beginning of value at offset 16", nil},
+		{"empty", ``, MalformedBody.Code, "request body is empty", nil},
+		{"truncated", `{"expression": "1"`, MalformedBody.Code, "request body is truncated", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			var p *Problem
+			router.POST("/", func(c *gin.Context) {
+				var req bindRequest
+				p = FromBindError(c.ShouldBindJSON(&req))
+			})
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+			if p.Code != tt.code || p.Detail != tt.detail || fmt.Sprint(p.Errors) != fmt.Sprint(tt.errors) {
+				t.Errorf("problem = %s %q %v, want %s %q %v", p.Code, p.Detail, p.Errors, tt.code, tt.detail, tt.errors)
+			}
+		})
+	}
+
+	others := []struct {
+		name   string
+		err    error
+		code   string
+		detail string
+	}{
+		{"problem", NotAcceptable.New("csv only"), NotAcceptable.Code, "csv only"},
+		{"too large", fmt.Errorf("read: %w", &http.MaxBytesError{Limit: 10}), PayloadTooLarge.Code, "request body is larger than 10 bytes"},
+		{"number", fmt.Errorf("bind: %w", &strconv.NumError{Func: "ParseFloat", Num: "ten", Err: strconv.ErrSyntax}), ValidationFailed.Code, "'ten' is not a valid number"},
+		{"time", &time.ParseError{Value: "yesterday"}, ValidationFailed.Code, "'yesterday' is not an RFC 3339 time"},
+		{"eof", fmt.Errorf("read: %w", io.EOF), MalformedBody.Code, "request body is empty"},
+		{"anything else", errors.New("something odd"), InvalidRequest.Code, "something odd"},
+	}
+	for _, tt := range others {
+		t.Run(tt.name, func(t *testing.T) {
+			if p := FromBindError(tt.err); p.Code != tt.code || p.Detail != tt.detail {
+				t.Errorf("problem = %s %q, want %s %q", p.Code, p.Detail, tt.code, tt.detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package problem
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"CalculatorWebService/internal/logger"
+)
+
+// Recovery replaces gin.Recovery: a panicking handler is logged with its stack and the client
+// gets an internal_error problem instead of an empty 500.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r) // the handler wants the connection dropped, let net/http do it
+			}
+			err, ok := r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+			if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+				// the client is gone, there is nobody to answer
+				c.Abort()
+				return
+			}
+
+			logger.LogErrorContext(c.Request.Context(), "Panic in HTTP handler", err, logrus.Fields{
+				"method": c.Request.Method,
+				"path":   c.Request.URL.Path,
+				"stack":  string(debug.Stack()),
+			})
+			if c.Writer.Written() {
+				c.Abort() // too late for a proper reply, the status is out already
+				return
+			}
+			Respond(c, Internal, "the request could not be completed")
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,114 @@
+// Package ratelimit bounds the request rate of every client with a token bucket per client.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"CalculatorWebService/internal/problem"
+)
+
+// minSweep is the number of buckets below which full ones are not swept
+const minSweep = 1024
+
+// Limiter lets every client make rate requests per second on average and up to burst at once.
+// A bucket that has refilled completely is no different from a new one, so those are forgotten
+// from time to time and idle clients take no memory.
+type Limiter struct {
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*bucket
+	sweepAt int // number of buckets that triggers the next sweep
+	mu      sync.Mutex
+
+	now func() time.Time // time.Now, tests move the clock
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time // when tokens was last brought up to date
+}
+
+// New returns a limiter of rate requests per second with bursts of burst requests.
+// burst below 1 allows a single request at once.
+func New(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		sweepAt: minSweep,
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token of the client. Without one the request must be refused, retryAfter is how long
+// until the client gets the next token.
+func (l *Limiter) Allow(client string) (ok bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	b, exists := l.buckets[client]
+	if !exists {
+		if len(l.buckets) >= l.sweepAt {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	l.refill(b, now)
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Middleware refuses the requests of a client over the limit with 429 and a Retry-After header.
+// Clients are told apart by gin's ClientIP, see the trusted proxies of the engine.
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, retryAfter := l.Allow(c.ClientIP())
+		if !ok {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			problem.Respond(c, problem.RateLimited, fmt.Sprintf("more than %g requests per second, retry in %d s", l.rate, seconds))
+			return
+		}
+		c.Next()
+	}
+}
+
+// Clients returns the number of clients the limiter remembers
+func (l *Limiter) Clients() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// refill must be called with the lock held
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed.Seconds()*l.rate)
+		b.last = now
+	}
+}
+
+// sweep forgets the full buckets, the next sweep waits until the map doubled again.
+// Must be called with the lock held.
+func (l *Limiter) sweep(now time.Time) {
+	for client, b := range l.buckets {
+		if l.refill(b, now); b.tokens >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+	l.sweepAt = max(minSweep, 2*len(l.buckets))
+}
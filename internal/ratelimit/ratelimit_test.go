@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"CalculatorWebService/internal/problem"
+)
+
+// clock is a time source the test moves by hand
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time           { return c.now }
+func (c *clock) Advance(by time.Duration) { c.now = c.now.Add(by) }
+
+func newLimiter(rate float64, burst int) (*Limiter, *clock) {
+	c := &clock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l := New(rate, burst)
+	l.now = c.Now
+	return l, c
+}
+
+func TestLimiter(t *testing.T) {
+	type step struct {
+		after      time.Duration // clock advance before the request
+		client     string
+		ok         bool
+		retryAfter time.Duration
+	}
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		steps []step
+	}{
+		{"burst then refused", 2, 3, []step{
+			{0, "a", true, 0}, {0, "a", true, 0}, {0, "a", true, 0},
+			{0, "a", false, 500 * time.Millisecond},
+			{0, "b", true, 0}, // clients have buckets of their own
+		}},
+		{"refill", 2, 1, []step{
+			{0, "a", true, 0},
+			{200 * time.Millisecond, "a", false, 300 * time.Millisecond},
+			{300 * time.Millisecond, "a", true, 0},
+		}},
+		{"refill stops at the burst", 10, 2, []step{
+			{0, "a", true, 0},
+			{time.Hour, "a", true, 0}, {0, "a", true, 0},
+			{0, "a", false, 100 * time.Millisecond},
+		}},
+		{"slow rate", 0.5, 1, []step{
+			{0, "a", true, 0},
+			{time.Second, "a", false, time.Second},
+		}},
+		{"no burst", 1, 0, []step{
+			{0, "a", true, 0},
+			{0, "a", false, time.Second},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, c := newLimiter(tt.rate, tt.burst)
+			for i, s := range tt.steps {
+				c.Advance(s.after)
+				ok, retryAfter := l.Allow(s.client)
+				if ok != s.ok || retryAfter.Round(time.Millisecond) != s.retryAfter {
+					t.Errorf("step %d: got %v, retry after %v, want %v, retry after %v", i, ok, retryAfter, s.ok, s.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterForgetsIdleClients(t *testing.T) {
+	l, c := newLimiter(10, 2)
+	for i := 0; i < minSweep-1; i++ {
+		l.Allow(fmt.Sprint("idle-", i))
+	}
+	l.Allow("busy")
+	l.Allow("busy")
+	c.Advance(time.Second) // the idle buckets are full again, the busy one isn't
+	l.Allow("busy")
+	l.Allow("busy")
+	if n := l.Clients(); n != minSweep {
+		t.Fatalf("%d clients before the sweep, want %d", n, minSweep)
+	}
+
+	l.Allow("new") // the map is full, the sweep comes first
+	if n := l.Clients(); n != 2 {
+		t.Errorf("%d clients after the sweep, want busy and new", n)
+	}
+	if ok, _ := l.Allow("busy"); ok {
+		t.Error("the sweep refilled the bucket of a busy client")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l, _ := newLimiter(1, 1)
+	router := gin.New()
+	router.Use(l.Middleware())
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+	if w := serve(); w.Code != http.StatusNoContent {
+		t.Fatalf("first request: status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	w := serve()
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("second request: status = %d, Retry-After %q, want %d and 1", w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+	var p problem.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Code != problem.RateLimited.Code {
+		t.Errorf("problem = %s, want %s", p.Code, problem.RateLimited.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"5f0c0e9e7f0f4a53", true},
+		{"client-42/retry:1", true},
+		{strings.Repeat("a", maxLength), true},
+		{"", false},
+		{strings.Repeat("a", maxLength+1), false},
+		{"with space", false},
+		{"line\nbreak", false},
+		{"naïve", false},
+	}
+	for _, tt := range tests {
+		if got := valid(tt.id); got != tt.want {
+			t.Errorf("valid(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"client ID", "client-42", true},
+		{"no ID", "", false},
+		{"invalid ID", "two words", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromGin, fromContext string
+			router := gin.New()
+			router.Use(Middleware())
+			router.GET("/", func(c *gin.Context) {
+				fromGin, fromContext = Get(c), FromContext(c.Request.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(Header)
+			if tt.keep && id != tt.header {
+				t.Errorf("ID = %q, want the client's %q", id, tt.header)
+			}
+			if !tt.keep && (len(id) != 32 || id == tt.header) {
+				t.Errorf("ID = %q, want a generated one", id)
+			}
+			if fromGin != id || fromContext != id {
+				t.Errorf("handler saw %q and %q, want %q", fromGin, fromContext, id)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor()
+	call := func(ctx context.Context) string {
+		var id string
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			id = FromContext(ctx)
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "client-42"))
+	if id := call(ctx); id != "client-42" {
+		t.Errorf("ID = %q, want the client's", id)
+	}
+	if id := call(context.Background()); len(id) != 32 {
+		t.Errorf("ID = %q, want a generated one", id)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := generate()
+		if !valid(id) || seen[id] {
+			t.Fatalf("generated %q, want a new valid ID", id)
+		}
+		seen[id] = true
+	}
+}
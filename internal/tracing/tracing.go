@@ -0,0 +1,167 @@
+// Package tracing sets up OpenTelemetry tracing: the tracer provider with its exporter, W3C trace context
+// propagation and the instrumentation of the Gin router and the gRPC server.
+// Like the logger it's process-wide, spans started before Init or with the none exporter are not recorded,
+// but an incoming traceparent is still propagated so logs carry the caller's trace ID.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/stats"
+
+	"CalculatorWebService/internal/config"
+)
+
+// tracerName is the instrumentation scope of the spans started with Start
+const tracerName = "CalculatorWebService"
+
+var (
+	provider    *sdktrace.TracerProvider
+	serviceName = "calculator"
+	closers     []func() error // files of the file exporter, closed after the last spans are written
+)
+
+func init() {
+	// propagation works without an exporter, see the package documentation
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Init installs the tracer provider exporting to cfg.Exporter, none keeps the no-op provider.
+// Spans are exported in batches, Shutdown flushes the last ones.
+func Init(cfg config.TracingConfig) error {
+	if cfg.ServiceName != "" {
+		serviceName = cfg.ServiceName
+	}
+	exporter, err := newExporter(cfg)
+	if err != nil || exporter == nil {
+		return err
+	}
+
+	res, err := resource.New(context.Background(),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(cfg.ServiceVersion),
+			semconv.HostName(cfg.ServerName),
+		),
+	)
+	if err != nil {
+		return fmt.Errorf("tracing resource: %w", err)
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return nil
+}
+
+// newExporter returns nil for the none exporter
+func newExporter(cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(cfg.Exporter) {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		file, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("tracing file: %w", err)
+		}
+		closers = append(closers, file.Close)
+		return stdouttrace.New(stdouttrace.WithWriter(file))
+	case "otlp":
+		return newOTLPExporter(cfg)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter '%s', available: none, otlp, stdout, file", cfg.Exporter)
+	}
+}
+
+// newOTLPExporter leaves unset options to the OTEL_EXPORTER_OTLP_* variables read by the exporters
+func newOTLPExporter(cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	ctx := context.Background()
+	switch strings.ToLower(cfg.OTLPProtocol) {
+	case "", "grpc":
+		var opts []otlptracegrpc.Option
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint))
+		}
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case "http":
+		var opts []otlptracehttp.Option
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.OTLPEndpoint))
+		}
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol '%s', available: grpc, http", cfg.OTLPProtocol)
+	}
+}
+
+// Shutdown exports the spans still buffered and stops the provider, it's a no-op without Init
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	err := provider.Shutdown(ctx)
+	for _, closeFile := range closers {
+		err = errors.Join(err, closeFile())
+	}
+	return err
+}
+
+// Middleware starts a server span per request, continuing the trace of an incoming traceparent.
+// It must come before LoggingMiddleware: the span is only in the request context until the middleware returns.
+// Prometheus scrapes are left out, they would be most of the traces.
+func Middleware() gin.HandlerFunc {
+	return otelgin.Middleware(serviceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return r.URL.Path != "/metrics"
+	}))
+}
+
+// ServerHandler is the gRPC counterpart of Middleware, installed with grpc.StatsHandler
+func ServerHandler() stats.Handler {
+	return otelgrpc.NewServerHandler()
+}
+
+// Start starts a span as a child of the span in ctx, it must be ended with End
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"CalculatorWebService/internal/config"
+)
+
+func TestNewExporter(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.TracingConfig
+		exporter bool
+		err      string
+	}{
+		{"default", config.TracingConfig{}, false, ""},
+		{"none", config.TracingConfig{Exporter: "None"}, false, ""},
+		{"stdout", config.TracingConfig{Exporter: "stdout"}, true, ""},
+		{"file", config.TracingConfig{Exporter: "file", FilePath: filepath.Join(t.TempDir(), "spans.json")}, true, ""},
+		{"file in a missing directory", config.TracingConfig{Exporter: "file", FilePath: filepath.Join(t.TempDir(), "missing", "spans.json")}, false, "tracing file"},
+		{"otlp over http", config.TracingConfig{Exporter: "otlp", OTLPProtocol: "http", OTLPEndpoint: "localhost:4318"}, true, ""},
+		{"unknown protocol", config.TracingConfig{Exporter: "otlp", OTLPProtocol: "udp"}, false, "unknown OTLP protocol 'udp'"},
+		{"unknown exporter", config.TracingConfig{Exporter: "zipkin"}, false, "unknown tracing exporter 'zipkin'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter, err := newExporter(tt.cfg)
+			if exporter != nil {
+				defer exporter.Shutdown(context.Background())
+			}
+			if (exporter != nil) != tt.exporter {
+				t.Errorf("exporter = %v, want one: %v", exporter, tt.exporter)
+			}
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+	for _, closeFile := range closers {
+		closeFile()
+	}
+	closers = nil
+}
+
+func TestShutdownWithoutInit(t *testing.T) {
+	if err := Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown without Init: %v", err)
+	}
+}
+
+func TestStartAndEnd(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	ctx, parent := Start(context.Background(), "evaluate")
+	_, child := Start(ctx, "storage.store")
+	End(child, errors.New("disk is full"))
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans ended, want 2", len(spans))
+	}
+	store, evaluate := spans[0], spans[1]
+	if store.Parent().SpanID() != evaluate.SpanContext().SpanID() {
+		t.Error("storage.store is not a child of evaluate")
+	}
+	if store.Status().Code != codes.Error || store.Status().Description != "disk is full" || len(store.Events()) != 1 {
+		t.Errorf("failed span: status %+v with %d events, want the error recorded", store.Status(), len(store.Events()))
+	}
+	if evaluate.Status().Code != codes.Unset || len(evaluate.Events()) != 0 {
+		t.Errorf("successful span: status %+v with %d events, want nothing recorded", evaluate.Status(), len(evaluate.Events()))
+	}
+}